@@ -5,6 +5,7 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/weijielee-galaxy/nccl-test-web/internal/handlers"
@@ -13,9 +14,27 @@ import (
 
 func main() {
 	// 命令行参数
-	port := flag.String("port", "8098", "Server port")
+	configFile := flag.String("config", os.Getenv(handlers.ConfigEnvPrefix+"CONFIG"), "Config file (YAML), defaults to $NCCL_WEB_CONFIG")
+	port := flag.String("port", "", "Server port, overrides the config file (default "+handlers.DefaultPort+")")
 	flag.Parse()
 
+	// 加载配置：内置默认值 < 配置文件 < NCCL_WEB_* 环境变量 < 命令行参数
+	cfg, err := handlers.LoadConfig(*configFile)
+	if err != nil {
+		log.Fatal("Failed to load config: ", err)
+	}
+	if *port != "" {
+		cfg.Port = *port
+		if err := cfg.Validate(); err != nil {
+			log.Fatal("Invalid -port: ", err)
+		}
+	}
+	cfg.Apply()
+	if *configFile != "" {
+		log.Printf("Loaded config from %s", *configFile)
+	}
+	log.Printf("Data directory: %s, history directory: %s", cfg.DataDir, cfg.HistoryDir)
+
 	// 创建 Gin 路由
 	r := gin.Default()
 
@@ -33,16 +52,54 @@ func main() {
 		v1.DELETE("/iplist/:filename", handlers.DeleteIPList) // 删除指定文件
 
 		// NCCL 测试接口
-		v1.GET("/nccl/defaults", handlers.GetNCCLTestDefaults)  // 获取默认参数
-		v1.POST("/nccl/run", handlers.RunNCCLTest)              // 运行测试（一次性返回）
-		v1.POST("/nccl/run-stream", handlers.RunNCCLTestStream) // 运行测试（流式返回）
-		v1.POST("/nccl/stop", handlers.StopNCCLTest)            // 停止当前运行的测试
-		v1.GET("/nccl/precheck", handlers.Precheck)             // 检查所有节点的 GPU 进程状态
+		v1.GET("/nccl/defaults", handlers.GetNCCLTestDefaults)            // 获取默认参数
+		v1.GET("/nccl/launchers", handlers.GetLaunchers)                  // 获取可用的启动器列表
+		v1.GET("/nccl/env-allowlist", handlers.GetEnvAllowlist)           // 获取环境变量白名单
+		v1.GET("/nccl/failure-signatures", handlers.GetFailureSignatures) // 获取失败特征库
+		v1.POST("/nccl/run", handlers.RunNCCLTest)                        // 运行测试（一次性返回）
+		v1.POST("/nccl/run-stream", handlers.RunNCCLTestStream)           // 运行测试（流式返回）
+		v1.POST("/nccl/stop", handlers.StopNCCLTest)                      // 停止测试（可通过 id 参数指定运行）
+		v1.GET("/nccl/precheck", handlers.Precheck)                       // 检查所有节点的 GPU 进程状态
+
+		// 异步运行接口
+		v1.POST("/runs", handlers.CreateRun)           // 创建运行，立即返回运行 ID
+		v1.GET("/runs", handlers.ListRuns)             // 获取运行列表
+		v1.GET("/runs/:id", handlers.GetRun)           // 获取指定运行的状态
+		v1.GET("/runs/:id/log", handlers.GetRunLog)    // 获取指定运行的输出
+		v1.GET("/runs/:id/stream", handlers.StreamRun) // 流式获取指定运行的输出（支持断点续传）
+		v1.GET("/runs/:id/logs", handlers.GetRunLogs)  // 查询 NCCL 调试日志（按节点、级别、内容过滤）
+		v1.POST("/runs/:id/stop", handlers.StopRun)    // 停止指定运行（排队中的运行直接取消）
+
+		// 运行队列接口
+		v1.GET("/queue", handlers.GetQueue)                // 获取排队中的运行和节点占用情况
+		v1.POST("/queue/:id/move", handlers.MoveQueuedRun) // 调整排队任务的位置
 
 		// 历史记录相关接口
-		v1.GET("/history", handlers.GetHistoryList)              // 获取历史记录列表
-		v1.GET("/history/:filename", handlers.GetHistoryContent) // 获取指定历史记录内容
-		v1.DELETE("/history/:filename", handlers.DeleteHistory)  // 删除指定历史记录
+		v1.GET("/history", handlers.GetHistoryList)                // 获取历史记录列表（含元数据，可按 collective、world_size、nccl_version、cuda_version 过滤）
+		v1.GET("/history/:id", handlers.GetHistoryContent)         // 获取指定历史记录的输出和元数据（兼容 <id>.txt）
+		v1.GET("/history/:id/results", handlers.GetHistoryResults) // 获取指定历史记录的解析结果
+		v1.DELETE("/history/:id", handlers.DeleteHistory)          // 删除指定历史记录
+		v1.POST("/history/:id/baseline", handlers.SetBaseline)     // 将历史运行设置为 IP 列表或集群的基准
+		v1.GET("/history/:id/junit", handlers.GetHistoryJUnit)     // 以 JUnit XML 格式获取验收结果
+
+		// 基准接口
+		v1.GET("/baselines", handlers.GetBaselines)            // 获取所有基准
+		v1.DELETE("/baselines/:name", handlers.DeleteBaseline) // 删除指定基准
+
+		// 验收标准接口
+		v1.GET("/criteria", handlers.GetCriteriaList)         // 获取所有命名验收标准
+		v1.GET("/criteria/:name", handlers.GetCriteria)       // 获取指定验收标准
+		v1.PUT("/criteria/:name", handlers.SaveCriteria)      // 创建或更新验收标准
+		v1.DELETE("/criteria/:name", handlers.DeleteCriteria) // 删除验收标准
+
+		// 活动接口：由多次子运行组成的自动化测试
+		v1.POST("/campaigns", handlers.CreateCampaign)        // 创建活动（locate、pairwise、single_node 等），立即返回活动 ID
+		v1.GET("/campaigns", handlers.ListCampaigns)          // 获取活动列表
+		v1.GET("/campaigns/:id", handlers.GetCampaign)        // 获取活动状态、子运行和报告
+		v1.POST("/campaigns/:id/stop", handlers.StopCampaign) // 停止活动及其进行中的子运行
+
+		// 运行对比接口
+		v1.GET("/compare", handlers.CompareHistory) // 对比多个运行的各大小带宽差异（?runs=a,b,c&tolerance=5）
 	}
 
 	// 嵌入前端静态文件
@@ -95,8 +152,8 @@ func main() {
 	})
 
 	// 启动服务器
-	log.Printf("Starting server on :%s", *port)
-	if err := r.Run(":" + *port); err != nil {
+	log.Printf("Starting server on :%s", cfg.Port)
+	if err := r.Run(":" + cfg.Port); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
 }
@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// BaselinesFile 基准运行配置文件，位于 DataDir 下
+	BaselinesFile = "baselines.json"
+	// DefaultRegressedThreshold 默认的回退阈值（百分比），总线带宽下降超过该值判定为 regressed
+	DefaultRegressedThreshold = 15.0
+)
+
+// 基准对比结论
+const (
+	VerdictOK        = "ok"        // 所有大小的总线带宽下降都在容差内
+	VerdictDegraded  = "degraded"  // 有大小的总线带宽下降超过容差，但未超过回退阈值
+	VerdictRegressed = "regressed" // 有大小的总线带宽下降超过回退阈值
+)
+
+// baselinesMu 保护基准配置文件的读写
+var baselinesMu sync.Mutex
+
+// Baseline 基准运行：相同节点集合和测试参数的后续运行会自动与其对比
+type Baseline struct {
+	Name               string         `json:"name"` // IP 列表文件名或集群名称
+	RunID              string         `json:"run_id"`
+	IPListFile         string         `json:"iplist_file"`
+	Hosts              []string       `json:"hosts"`     // 排序后的节点名称
+	Signature          string         `json:"signature"` // 影响性能的测试参数摘要
+	Params             NCCLTestParams `json:"params"`
+	Tolerance          float64        `json:"tolerance"`           // 总线带宽下降超过该百分比判定为 degraded
+	RegressedThreshold float64        `json:"regressed_threshold"` // 总线带宽下降超过该百分比判定为 regressed
+	MinSize            int            `json:"min_size"`            // 只比较不小于该大小的数据点，小消息的带宽波动较大
+	CreatedBy          string         `json:"created_by"`
+	CreatedAt          time.Time      `json:"created_at"`
+}
+
+// SetBaselineRequest 将历史运行设置为基准的请求
+type SetBaselineRequest struct {
+	Name               string   `json:"name"`                // 基准名称，为空时使用运行的 IP 列表文件名
+	Tolerance          *float64 `json:"tolerance"`           // 默认 5
+	RegressedThreshold *float64 `json:"regressed_threshold"` // 默认 15
+	MinSize            int      `json:"min_size"`            // 默认 0，比较所有大小
+}
+
+// BaselineVerdict 运行与基准对比的结论
+type BaselineVerdict struct {
+	Verdict            string          `json:"verdict"` // ok, degraded, regressed
+	Baseline           string          `json:"baseline"`
+	BaselineRun        string          `json:"baseline_run"`
+	Tolerance          float64         `json:"tolerance"`
+	RegressedThreshold float64         `json:"regressed_threshold"`
+	WorstPct           float64         `json:"worst_pct"`       // 总线带宽的最大下降百分比（负数表示下降）
+	ComparedSizes      int             `json:"compared_sizes"`  // 参与比较的大小数量
+	OffendingSizes     []OffendingSize `json:"offending_sizes"` // 下降超过容差的大小
+}
+
+// OffendingSize 总线带宽下降超过容差的大小
+type OffendingSize struct {
+	Size     int     `json:"size"`
+	Type     string  `json:"type"`
+	Metric   string  `json:"metric"` // out_busbw 或 in_busbw
+	Baseline float64 `json:"baseline"`
+	Value    float64 `json:"value"`
+	Pct      float64 `json:"pct"`
+	Verdict  string  `json:"verdict"` // degraded 或 regressed
+}
+
+// loadBaselines 读取所有基准，文件不存在时返回空集合（调用方需持有 baselinesMu）
+func loadBaselines() (map[string]Baseline, error) {
+	baselines := make(map[string]Baseline)
+
+	data, err := os.ReadFile(filepath.Join(DataDir, BaselinesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return baselines, nil
+		}
+		return nil, fmt.Errorf("failed to read baselines: %v", err)
+	}
+	if err := json.Unmarshal(data, &baselines); err != nil {
+		return nil, fmt.Errorf("failed to parse baselines: %v", err)
+	}
+	return baselines, nil
+}
+
+// saveBaselines 保存所有基准（调用方需持有 baselinesMu）
+func saveBaselines(baselines map[string]Baseline) error {
+	if err := os.MkdirAll(DataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(baselines, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode baselines: %v", err)
+	}
+
+	filename := filepath.Join(DataDir, BaselinesFile)
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write baselines: %v", err)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write baselines: %v", err)
+	}
+	return nil
+}
+
+// paramsSignature 计算影响性能的测试参数摘要
+// 超时、调试日志、IP 列表文件名和验收标准不影响结果，不参与计算；大小参数统一为字节数
+func paramsSignature(params NCCLTestParams) string {
+	params.Timeout = 0
+	params.EnableDebug = false
+	params.NCCLDebugLevel = ""
+	params.IPListFile = ""
+	params.Criteria = ""
+	params.Thresholds = nil
+	if params.Launcher == "" {
+		params.Launcher = DefaultLauncher
+	}
+	for _, size := range []*interface{}{&params.TestSizeBegin, &params.TestSizeEnd, &params.StepBytes} {
+		if n, err := parseSize(sizeArg(*size)); err == nil {
+			*size = n
+		} else {
+			*size = nil
+		}
+	}
+
+	data, _ := json.Marshal(params)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// sortedHostNames 返回排序后的节点名称，用于比较节点集合
+func sortedHostNames(hosts []string) []string {
+	names := hostNames(hosts)
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// sameHosts 判断两个排序后的节点集合是否相同
+func sameHosts(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// findBaseline 查找与运行匹配的基准：节点集合和测试参数都相同
+// 有多个匹配时优先使用以运行的 IP 列表文件命名的基准，其次使用最新设置的基准
+func findBaseline(meta HistoryMeta) (*Baseline, error) {
+	baselinesMu.Lock()
+	baselines, err := loadBaselines()
+	baselinesMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := sortedHostNames(meta.Hosts)
+	signature := paramsSignature(meta.Params)
+
+	var best *Baseline
+	for _, b := range baselines {
+		if b.RunID == meta.ID || b.Signature != signature || !sameHosts(b.Hosts, hosts) {
+			continue
+		}
+		b := b
+		switch {
+		case best == nil:
+			best = &b
+		case (b.Name == meta.IPListFile) != (best.Name == meta.IPListFile):
+			if b.Name == meta.IPListFile {
+				best = &b
+			}
+		case b.CreatedAt.After(best.CreatedAt):
+			best = &b
+		}
+	}
+	return best, nil
+}
+
+// evaluateBaseline 将运行结果与匹配的基准对比，没有匹配的基准或没有数据点时返回 nil
+func evaluateBaseline(meta HistoryMeta, results RunResults) (*BaselineVerdict, error) {
+	if len(results.DataPoints) == 0 {
+		return nil, nil
+	}
+
+	baseline, err := findBaseline(meta)
+	if err != nil || baseline == nil {
+		return nil, err
+	}
+
+	baseResults, err := loadHistoryResults(baseline.RunID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load baseline run %s: %v", baseline.RunID, err)
+	}
+
+	verdict := judgeBaseline(*baseline, baseResults.DataPoints, results.DataPoints)
+	return &verdict, nil
+}
+
+// judgeBaseline 按总线带宽的下降幅度给出结论，只关注下降，提升不算异常
+func judgeBaseline(baseline Baseline, base, run []ChartDataPoint) BaselineVerdict {
+	verdict := BaselineVerdict{
+		Verdict:            VerdictOK,
+		Baseline:           baseline.Name,
+		BaselineRun:        baseline.RunID,
+		Tolerance:          baseline.Tolerance,
+		RegressedThreshold: baseline.RegressedThreshold,
+		OffendingSizes:     []OffendingSize{},
+	}
+
+	cmp := compareSeries(base, run, true, math.Inf(1))
+	for _, sc := range cmp.Sizes {
+		if sc.Size < baseline.MinSize {
+			continue
+		}
+		verdict.ComparedSizes++
+
+		for _, metric := range []string{"out_busbw", "in_busbw"} {
+			delta := sc.Deltas[metric]
+			if delta.Pct == nil {
+				continue
+			}
+			pct := *delta.Pct
+			if pct < verdict.WorstPct {
+				verdict.WorstPct = pct
+			}
+			if -pct <= baseline.Tolerance {
+				continue
+			}
+
+			level := VerdictDegraded
+			if -pct > baseline.RegressedThreshold {
+				level = VerdictRegressed
+			}
+			if level == VerdictRegressed || verdict.Verdict == VerdictOK {
+				verdict.Verdict = level
+			}
+			verdict.OffendingSizes = append(verdict.OffendingSizes, OffendingSize{
+				Size:     sc.Size,
+				Type:     sc.Type,
+				Metric:   metric,
+				Baseline: delta.Baseline,
+				Value:    delta.Value,
+				Pct:      pct,
+				Verdict:  level,
+			})
+		}
+	}
+	return verdict
+}
+
+// GetBaselines 获取所有基准
+func GetBaselines(c *gin.Context) {
+	baselinesMu.Lock()
+	baselines, err := loadBaselines()
+	baselinesMu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	list := make([]Baseline, 0, len(baselines))
+	for _, b := range baselines {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":     len(list),
+		"baselines": list,
+	})
+}
+
+// SetBaseline 将历史运行设置为基准，同名基准会被替换
+func SetBaseline(c *gin.Context) {
+	id, ok := historyID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid history ID",
+		})
+		return
+	}
+
+	var req SetBaselineRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	meta, err := loadHistoryMeta(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if meta == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "History record not found or has no metadata",
+		})
+		return
+	}
+	if meta.Status != RunStatusSuccess {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Run finished with status %s and cannot be used as a baseline", meta.Status),
+		})
+		return
+	}
+	results, err := loadHistoryResults(id)
+	if err != nil || len(results.DataPoints) == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Run has no parsed results and cannot be used as a baseline",
+		})
+		return
+	}
+
+	baseline := Baseline{
+		Name:               req.Name,
+		RunID:              id,
+		IPListFile:         meta.IPListFile,
+		Hosts:              sortedHostNames(meta.Hosts),
+		Signature:          paramsSignature(meta.Params),
+		Params:             meta.Params,
+		Tolerance:          DefaultCompareTolerance,
+		RegressedThreshold: DefaultRegressedThreshold,
+		MinSize:            req.MinSize,
+		CreatedBy:          requestOwner(c, ""),
+		CreatedAt:          time.Now(),
+	}
+	if baseline.Name == "" {
+		baseline.Name = meta.IPListFile
+	}
+	if req.Tolerance != nil {
+		baseline.Tolerance = *req.Tolerance
+	}
+	if req.RegressedThreshold != nil {
+		baseline.RegressedThreshold = *req.RegressedThreshold
+	}
+
+	fields := make(map[string]string)
+	if !filenamePattern.MatchString(baseline.Name) {
+		fields["name"] = "must contain only letters, digits, '.', '_' and '-'"
+	}
+	if baseline.Tolerance < 0 {
+		fields["tolerance"] = "must not be negative"
+	}
+	if baseline.RegressedThreshold < baseline.Tolerance {
+		fields["regressed_threshold"] = "must not be smaller than tolerance"
+	}
+	if baseline.MinSize < 0 {
+		fields["min_size"] = "must not be negative"
+	}
+	if len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Invalid parameters",
+			"fields": fields,
+		})
+		return
+	}
+
+	baselinesMu.Lock()
+	defer baselinesMu.Unlock()
+
+	baselines, err := loadBaselines()
+	if err == nil {
+		baselines[baseline.Name] = baseline
+		err = saveBaselines(baselines)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	fmt.Printf("Baseline %s set to run %s by %s\n", baseline.Name, id, baseline.CreatedBy)
+	c.JSON(http.StatusOK, baseline)
+}
+
+// DeleteBaseline 删除指定的基准，不影响历史记录
+func DeleteBaseline(c *gin.Context) {
+	name := c.Param("name")
+
+	baselinesMu.Lock()
+	defer baselinesMu.Unlock()
+
+	baselines, err := loadBaselines()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if _, ok := baselines[name]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Baseline not found",
+		})
+		return
+	}
+
+	delete(baselines, name)
+	if err := saveBaselines(baselines); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Baseline deleted successfully",
+	})
+}
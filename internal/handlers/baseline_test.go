@@ -0,0 +1,71 @@
+package handlers
+
+import "testing"
+
+func TestJudgeBaseline(t *testing.T) {
+	baseline := Baseline{Name: "default", RunID: "base", Tolerance: 5, RegressedThreshold: 15, MinSize: 2048}
+	base := []ChartDataPoint{
+		comparePoint(1024, "float", 10),
+		comparePoint(2048, "float", 100),
+		comparePoint(4096, "float", 200),
+	}
+
+	testCases := []struct {
+		desc      string
+		run       []ChartDataPoint
+		verdict   string
+		offending int
+	}{
+		{
+			desc:    "容差范围内",
+			run:     []ChartDataPoint{comparePoint(1024, "float", 10), comparePoint(2048, "float", 97), comparePoint(4096, "float", 210)},
+			verdict: VerdictOK,
+		},
+		{
+			desc:    "小于 min_size 的大小不参与比较",
+			run:     []ChartDataPoint{comparePoint(1024, "float", 1), comparePoint(2048, "float", 100), comparePoint(4096, "float", 200)},
+			verdict: VerdictOK,
+		},
+		{
+			desc:      "下降超过容差",
+			run:       []ChartDataPoint{comparePoint(1024, "float", 10), comparePoint(2048, "float", 90), comparePoint(4096, "float", 200)},
+			verdict:   VerdictDegraded,
+			offending: 2, // out_busbw 和 in_busbw
+		},
+		{
+			desc:      "下降超过回退阈值",
+			run:       []ChartDataPoint{comparePoint(1024, "float", 10), comparePoint(2048, "float", 90), comparePoint(4096, "float", 100)},
+			verdict:   VerdictRegressed,
+			offending: 4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			verdict := judgeBaseline(baseline, base, tc.run)
+			if verdict.Verdict != tc.verdict || len(verdict.OffendingSizes) != tc.offending {
+				t.Errorf("verdict = %s with %d offending sizes, want %s with %d: %+v",
+					verdict.Verdict, len(verdict.OffendingSizes), tc.verdict, tc.offending, verdict.OffendingSizes)
+			}
+			if verdict.ComparedSizes != 2 {
+				t.Errorf("compared sizes = %d, want 2", verdict.ComparedSizes)
+			}
+		})
+	}
+}
+
+func TestParamsSignature(t *testing.T) {
+	a := validParams()
+	b := validParams()
+	b.Timeout = 1200
+	b.IPListFile = "cluster-b"
+	b.TestSizeBegin = float64(8 << 10) // 与 "8K" 相同
+	if paramsSignature(a) != paramsSignature(b) {
+		t.Errorf("timeout, iplist_file and size notation should not change the signature")
+	}
+
+	b.Datatype = "half"
+	if paramsSignature(a) == paramsSignature(b) {
+		t.Errorf("datatype should change the signature")
+	}
+}
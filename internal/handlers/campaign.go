@@ -0,0 +1,650 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 活动类型：由多次子运行组成的自动化测试流程
+const (
+	CampaignLocate     = "locate"      // 反复拆分节点集合，定位导致运行变慢或失败的节点
+	CampaignPairwise   = "pairwise"    // 节点两两运行，生成带宽矩阵
+	CampaignSingleNode = "single_node" // 每个节点单独运行，检查节点内 GPU（NVLink）
+	CampaignScaling    = "scaling"     // 在逐渐增加的节点数上运行，比较带宽的扩展性
+	CampaignSweep      = "sweep"       // 依次运行多组 NCCL 参数，按带宽排名
+)
+
+// 活动状态
+const (
+	CampaignStatusRunning = "running"
+	CampaignStatusSuccess = "success" // 活动执行完毕，结论见报告
+	CampaignStatusError   = "error"
+	CampaignStatusStopped = "stopped"
+)
+
+const (
+	// CampaignsDir 活动记录目录，位于 DataDir 下，每个活动一个 <id>.json 文件
+	CampaignsDir = "campaigns"
+)
+
+// errCampaignStopped 活动被用户停止
+var errCampaignStopped = errors.New("campaign stopped by user")
+
+// CampaignRequest 创建活动的请求
+type CampaignRequest struct {
+	Type   string         `json:"type" binding:"required"` // 活动类型，如 locate
+	Owner  string         `json:"owner"`                   // 发起人，未填写时使用 X-User 请求头或客户端 IP
+	Params NCCLTestParams `json:"params"`                  // 子运行的测试参数，iplist_file 指定参与活动的节点
+
+	Locate     *LocateOptions     `json:"locate,omitempty"`
+	Pairwise   *PairwiseOptions   `json:"pairwise,omitempty"`
+	SingleNode *SingleNodeOptions `json:"single_node,omitempty"`
+	Scaling    *ScalingOptions    `json:"scaling,omitempty"`
+	Sweep      *SweepOptions      `json:"sweep,omitempty"`
+}
+
+// CampaignRun 活动中的一次子运行，是活动结论的依据
+type CampaignRun struct {
+	ID        string   `json:"id"` // 运行 ID，可通过 /runs/:id 或 /history/:id 查看
+	Step      int      `json:"step"`
+	Label     string   `json:"label"`
+	Hosts     []string `json:"hosts"` // 节点名
+	Status    string   `json:"status"`
+	Passed    bool     `json:"passed"` // 运行成功且满足验收阈值
+	PeakBusbw float64  `json:"peak_busbw"`
+	AvgBusbw  *float64 `json:"avg_busbw"`
+	Reasons   []string `json:"reasons,omitempty"` // 未通过的原因
+}
+
+// CampaignInfo 活动信息快照
+type CampaignInfo struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Owner   string          `json:"owner"`
+	Status  string          `json:"status"`
+	Error   string          `json:"error,omitempty"`
+	Request CampaignRequest `json:"request"`
+	Hosts   []string        `json:"hosts"` // 参与活动的节点名
+	Runs    []CampaignRun   `json:"runs"`
+
+	LocateReport     *LocateReport     `json:"locate_report,omitempty"`
+	PairwiseReport   *PairwiseReport   `json:"pairwise_report,omitempty"`
+	SingleNodeReport *SingleNodeReport `json:"single_node_report,omitempty"`
+	ScalingReport    *ScalingReport    `json:"scaling_report,omitempty"`
+	SweepReport      *SweepReport      `json:"sweep_report,omitempty"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Campaign 一次活动
+type Campaign struct {
+	mu      sync.Mutex
+	info    CampaignInfo
+	hosts   []string        // IP 列表文件中的条目，可能带 slots=N
+	active  map[string]*Run // 进行中的子运行
+	stopped bool
+	done    chan struct{}
+}
+
+// campaignTest 一次待提交的子运行
+type campaignTest struct {
+	Label  string
+	Hosts  []string // IP 列表条目
+	Params NCCLTestParams
+}
+
+// campaignResult 子运行结束后的结果
+type campaignResult struct {
+	Run     CampaignRun
+	Results RunResults
+}
+
+// campaignType 活动类型的参数校验和执行流程
+type campaignType struct {
+	// validate 校验并补全活动参数，返回字段名到错误信息的映射
+	validate func(req *CampaignRequest, hosts []string) map[string]string
+	// run 执行活动，返回的错误记录在活动中
+	run func(c *Campaign) error
+}
+
+// campaignTypes 支持的活动类型
+var campaignTypes = map[string]campaignType{
+	CampaignLocate:     {validate: validateLocate, run: runLocate},
+	CampaignPairwise:   {validate: validatePairwise, run: runPairwise},
+	CampaignSingleNode: {validate: validateSingleNode, run: runSingleNode},
+	CampaignScaling:    {validate: validateScaling, run: runScaling},
+	CampaignSweep:      {validate: validateSweep, run: runSweep},
+}
+
+// CampaignManager 活动注册表，管理本次启动后创建的活动
+type CampaignManager struct {
+	mu        sync.Mutex
+	campaigns map[string]*Campaign
+}
+
+// NewCampaignManager 创建新的活动注册表
+func NewCampaignManager() *CampaignManager {
+	return &CampaignManager{campaigns: make(map[string]*Campaign)}
+}
+
+// defaultCampaignManager 全局活动注册表
+var defaultCampaignManager = NewCampaignManager()
+
+// Submit 校验并启动一次活动，立即返回
+func (m *CampaignManager) Submit(req CampaignRequest) (*Campaign, error) {
+	kind, ok := campaignTypes[req.Type]
+	if !ok {
+		return nil, fmt.Errorf("invalid campaign type: %s", req.Type)
+	}
+	if err := req.Params.Validate(); err != nil {
+		var invalid *ValidationError
+		if errors.As(err, &invalid) {
+			fields := make(map[string]string, len(invalid.Fields))
+			for field, msg := range invalid.Fields {
+				fields["params."+field] = msg
+			}
+			return nil, &ValidationError{Fields: fields}
+		}
+		return nil, err
+	}
+
+	hosts, err := loadIPList(req.Params.IPListFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("iplist file %s has no hosts", req.Params.IPListFile)
+	}
+	if fields := kind.validate(&req, hosts); len(fields) > 0 {
+		return nil, &ValidationError{Fields: fields}
+	}
+
+	id, err := newRunID()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Campaign{
+		info: CampaignInfo{
+			ID:        id,
+			Type:      req.Type,
+			Owner:     req.Owner,
+			Status:    CampaignStatusRunning,
+			Request:   req,
+			Hosts:     hostNames(hosts),
+			Runs:      []CampaignRun{},
+			CreatedAt: time.Now(),
+		},
+		hosts:  hosts,
+		active: make(map[string]*Run),
+		done:   make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.campaigns[id] = c
+	m.mu.Unlock()
+
+	c.save()
+	fmt.Printf("Campaign %s (%s) started by %s on %d hosts\n", id, req.Type, req.Owner, len(hosts))
+	go m.execute(c, kind.run)
+	return c, nil
+}
+
+// execute 执行活动并记录最终状态
+func (m *CampaignManager) execute(c *Campaign, run func(c *Campaign) error) {
+	err := run(c)
+
+	finished := time.Now()
+	c.mu.Lock()
+	c.info.FinishedAt = &finished
+	switch {
+	case c.stopped:
+		c.info.Status = CampaignStatusStopped
+		c.info.Error = "Stopped by user"
+	case err != nil:
+		c.info.Status = CampaignStatusError
+		c.info.Error = err.Error()
+	default:
+		c.info.Status = CampaignStatusSuccess
+	}
+	status := c.info.Status
+	c.mu.Unlock()
+
+	// 先保存最终状态，活动结束后读取记录的调用方不会读到进行中的状态
+	c.save()
+	close(c.done)
+	fmt.Printf("Campaign %s finished: %s\n", c.info.ID, status)
+}
+
+// Get 根据 ID 获取活动
+func (m *CampaignManager) Get(id string) (*Campaign, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.campaigns[id]
+	return c, ok
+}
+
+// List 返回本次启动后创建的所有活动
+func (m *CampaignManager) List() []*Campaign {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	campaigns := make([]*Campaign, 0, len(m.campaigns))
+	for _, c := range m.campaigns {
+		campaigns = append(campaigns, c)
+	}
+	return campaigns
+}
+
+// Stop 停止活动：不再提交新的子运行，并停止进行中的子运行
+func (m *CampaignManager) Stop(c *Campaign) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Finished() {
+		return errors.New("campaign already finished")
+	}
+	c.stopped = true
+	for _, run := range c.active {
+		if !run.Finished() {
+			if err := defaultRunManager.Stop(run); err != nil {
+				fmt.Printf("Campaign %s: failed to stop run %s: %v\n", c.info.ID, run.ID(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// Info 返回活动信息快照
+func (c *Campaign) Info() CampaignInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info := c.info
+	info.Runs = append([]CampaignRun(nil), c.info.Runs...)
+	return info
+}
+
+// Done 返回活动结束时关闭的通道
+func (c *Campaign) Done() <-chan struct{} {
+	return c.done
+}
+
+// Finished 判断活动是否已结束
+func (c *Campaign) Finished() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// update 修改活动信息并保存
+func (c *Campaign) update(fn func(info *CampaignInfo)) {
+	c.mu.Lock()
+	fn(&c.info)
+	c.mu.Unlock()
+	c.save()
+}
+
+// runTests 提交一轮子运行并等待全部结束，节点不重叠的子运行会同时进行
+// 活动被停止时返回 errCampaignStopped
+func (c *Campaign) runTests(step int, tests []campaignTest) ([]campaignResult, error) {
+	runs := make([]*Run, 0, len(tests))
+	for _, test := range tests {
+		run, err := c.submit(step, test)
+		if err != nil {
+			for i, run := range runs {
+				if !run.Finished() {
+					defaultRunManager.Stop(run)
+				}
+				<-run.Done()
+				c.finish(step, tests[i], run)
+			}
+			c.save()
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	results := make([]campaignResult, len(runs))
+	for i, run := range runs {
+		<-run.Done()
+		results[i] = c.finish(step, tests[i], run)
+	}
+	c.save()
+
+	if c.isStopped() {
+		return nil, errCampaignStopped
+	}
+	return results, nil
+}
+
+// runParallel 并行运行一组子运行，同时最多 limit 个，每个子运行结束时保存进度
+// 有子运行提交失败时不再提交新的子运行，返回已结束的子运行（包括提交失败的）和第一个错误
+// 活动被停止时返回已结束的子运行和 errCampaignStopped
+func (c *Campaign) runParallel(step int, tests []campaignTest, limit int) ([]campaignResult, error) {
+	results := make([]*campaignResult, len(tests))
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	// 创建信号量来限制并发数
+	semaphore := make(chan struct{}, limit)
+
+	for i, test := range tests {
+		wg.Add(1)
+		go func(index int, test campaignTest) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			mu.Lock()
+			failed := firstErr != nil
+			mu.Unlock()
+			if failed {
+				return
+			}
+
+			run, err := c.submit(step, test)
+			if err != nil {
+				if errors.Is(err, errCampaignStopped) {
+					return
+				}
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				result := newFailedCampaignResult(step, test, err)
+				results[index] = &result
+				return
+			}
+			<-run.Done()
+			result := c.finish(step, test, run)
+			results[index] = &result
+			c.save()
+		}(i, test)
+	}
+
+	wg.Wait()
+	done := make([]campaignResult, 0, len(tests))
+	for _, result := range results {
+		if result != nil {
+			done = append(done, *result)
+		}
+	}
+	if c.isStopped() {
+		return done, errCampaignStopped
+	}
+	return done, firstErr
+}
+
+// submit 提交一次子运行，活动被停止时返回 errCampaignStopped
+func (c *Campaign) submit(step int, test campaignTest) (*Run, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopped {
+		return nil, errCampaignStopped
+	}
+	run, err := defaultRunManager.Submit(RunRequest{
+		NCCLTestParams: test.Params,
+		Owner:          c.info.Owner,
+		Hosts:          test.Hosts,
+		Campaign:       c.info.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit %s: %v", test.Label, err)
+	}
+	c.active[run.ID()] = run
+	fmt.Printf("Campaign %s step %d: run %s on %s\n", c.info.ID, step, run.ID(), strings.Join(hostNames(test.Hosts), ","))
+	return run, nil
+}
+
+// finish 记录结束的子运行
+func (c *Campaign) finish(step int, test campaignTest, run *Run) campaignResult {
+	result := newCampaignResult(step, test, run)
+
+	c.mu.Lock()
+	delete(c.active, run.ID())
+	c.info.Runs = append(c.info.Runs, result.Run)
+	c.mu.Unlock()
+	return result
+}
+
+// isStopped 判断活动是否被停止
+func (c *Campaign) isStopped() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stopped
+}
+
+// newCampaignResult 根据结束的运行生成子运行记录
+func newCampaignResult(step int, test campaignTest, run *Run) campaignResult {
+	info := run.Info()
+	results := ParseRunResults(info.ID, run.Log().String())
+
+	record := CampaignRun{
+		ID:        info.ID,
+		Step:      step,
+		Label:     test.Label,
+		Hosts:     hostNames(test.Hosts),
+		Status:    info.Status,
+		PeakBusbw: results.Summary.PeakBusbw,
+		AvgBusbw:  results.Summary.AvgBusbw,
+	}
+	switch {
+	case info.Status != RunStatusSuccess:
+		record.Reasons = append(record.Reasons, info.Error)
+		for _, failure := range info.Failures {
+			record.Reasons = append(record.Reasons, failure.Description)
+		}
+	case info.Acceptance != nil && info.Acceptance.Result != AcceptancePass:
+		record.Reasons = info.Acceptance.Reasons
+	default:
+		record.Passed = true
+	}
+	return campaignResult{Run: record, Results: results}
+}
+
+// newFailedCampaignResult 生成提交失败的子运行记录，没有运行 ID
+func newFailedCampaignResult(step int, test campaignTest, err error) campaignResult {
+	return campaignResult{Run: CampaignRun{
+		Step:    step,
+		Label:   test.Label,
+		Hosts:   hostNames(test.Hosts),
+		Status:  RunStatusError,
+		Reasons: []string{err.Error()},
+	}}
+}
+
+// save 保存活动记录，失败时只记录日志
+func (c *Campaign) save() {
+	info := c.Info()
+	if err := writeCampaign(info); err != nil {
+		fmt.Printf("Failed to save campaign %s: %v\n", info.ID, err)
+	}
+}
+
+// writeCampaign 写入活动记录，先写临时文件再重命名，避免读到不完整的内容
+func writeCampaign(info CampaignInfo) error {
+	dir := filepath.Join(DataDir, CampaignsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create campaigns directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode campaign: %v", err)
+	}
+
+	filename := filepath.Join(dir, info.ID+".json")
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write campaign: %v", err)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write campaign: %v", err)
+	}
+	return nil
+}
+
+// loadCampaign 读取保存的活动记录，不存在时返回 os.ErrNotExist
+// 服务重启前未结束的活动无法继续，状态记为 error
+func loadCampaign(id string) (*CampaignInfo, error) {
+	if !filenamePattern.MatchString(id) {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(filepath.Join(DataDir, CampaignsDir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var info CampaignInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse campaign %s: %v", id, err)
+	}
+	if info.Status == CampaignStatusRunning {
+		info.Status = CampaignStatusError
+		info.Error = "Interrupted by server restart"
+	}
+	return &info, nil
+}
+
+// getCampaignInfo 获取活动信息，优先使用内存中的活动
+func getCampaignInfo(id string) (*CampaignInfo, error) {
+	if c, ok := defaultCampaignManager.Get(id); ok {
+		info := c.Info()
+		return &info, nil
+	}
+	return loadCampaign(id)
+}
+
+// CreateCampaign 创建活动，立即返回活动 ID
+func CreateCampaign(c *gin.Context) {
+	var req CampaignRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Owner = requestOwner(c, req.Owner)
+
+	campaign, err := defaultCampaignManager.Submit(req)
+	if err != nil {
+		respondSubmitError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, campaign.Info())
+}
+
+// ListCampaigns 获取活动列表，包括之前启动时保存的活动，最新的在前
+func ListCampaigns(c *gin.Context) {
+	infos := []CampaignInfo{}
+	seen := make(map[string]bool)
+	for _, campaign := range defaultCampaignManager.List() {
+		info := campaign.Info()
+		infos = append(infos, info)
+		seen[info.ID] = true
+	}
+
+	entries, err := os.ReadDir(filepath.Join(DataDir, CampaignsDir))
+	if err != nil && !os.IsNotExist(err) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to read campaigns directory: %v", err),
+		})
+		return
+	}
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" || seen[id] {
+			continue
+		}
+		info, err := loadCampaign(id)
+		if err != nil {
+			fmt.Printf("Failed to load campaign %s: %v\n", id, err)
+			continue
+		}
+		infos = append(infos, *info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.After(infos[j].CreatedAt)
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":     len(infos),
+		"campaigns": infos,
+	})
+}
+
+// GetCampaign 获取指定活动的信息、子运行和报告
+func GetCampaign(c *gin.Context) {
+	info, err := getCampaignInfo(c.Param("id"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Campaign not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// StopCampaign 停止指定活动，进行中的子运行会被停止
+func StopCampaign(c *gin.Context) {
+	campaign, ok := defaultCampaignManager.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Campaign not found",
+		})
+		return
+	}
+
+	if campaign.Finished() {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "finished",
+			"message": "Campaign already finished",
+		})
+		return
+	}
+
+	if err := defaultCampaignManager.Stop(campaign); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      campaign.info.ID,
+		"status":  "stopped",
+		"message": "Campaign stopped successfully",
+	})
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// DefaultLocateGroups 定位时每轮把可疑节点分成的组数（二分）
+	DefaultLocateGroups = 2
+	// MaxLocateGroups 定位时每轮最多分成的组数
+	MaxLocateGroups = 16
+	// DefaultLocateMaxRuns 定位活动默认的子运行数量上限
+	DefaultLocateMaxRuns = 32
+	// MaxLocateMaxRuns 定位活动子运行数量上限的最大值
+	MaxLocateMaxRuns = 256
+	// minLocateHosts 子集的最少节点数，单节点运行不经过网络，无法复现跨节点的问题
+	minLocateHosts = 2
+)
+
+// LocateOptions 定位活动的参数
+// 子集是否通过由 params 中的 criteria/thresholds 判定，子运行使用与 params 相同的测试参数，
+// 建议缩小 test_size_begin/test_size_end 和 iters 以缩短每轮的时间
+type LocateOptions struct {
+	Groups      int  `json:"groups"`       // 每轮把可疑节点分成几组，默认 2
+	MaxRuns     int  `json:"max_runs"`     // 子运行数量上限，达到上限时停止缩小范围，默认 32
+	SkipInitial bool `json:"skip_initial"` // 跳过对全部节点的确认运行，直接开始拆分
+}
+
+// LocateReport 定位活动的结论
+type LocateReport struct {
+	Conclusion string     `json:"conclusion"`
+	Suspects   []string   `json:"suspects"`   // 单独与正常节点组合时复现问题的节点
+	Unresolved [][]string `json:"unresolved"` // 无法继续缩小的可疑节点组：拆分后的子集都通过，或没有正常节点可以配对，或达到子运行上限
+	Cleared    []string   `json:"cleared"`    // 在通过的子运行中出现过的节点
+}
+
+// locatePlan 一个可疑节点组在本轮的测试方式
+type locatePlan struct {
+	group []string   // 可疑节点组
+	parts [][]string // 每个子运行要判定的节点：拆分时是子集，配对时是单个节点
+	split bool       // true 表示拆分为子集，false 表示每个节点与正常节点配对
+}
+
+// validateLocate 校验定位活动的参数并填充默认值
+func validateLocate(req *CampaignRequest, hosts []string) map[string]string {
+	fields := make(map[string]string)
+	if req.Locate == nil {
+		req.Locate = &LocateOptions{}
+	}
+	opts := req.Locate
+	if opts.Groups == 0 {
+		opts.Groups = DefaultLocateGroups
+	}
+	if opts.MaxRuns == 0 {
+		opts.MaxRuns = DefaultLocateMaxRuns
+	}
+
+	if req.Params.Criteria == "" && len(req.Params.Thresholds) == 0 {
+		fields["params.thresholds"] = "locate needs criteria or thresholds to decide whether a subset is healthy"
+	}
+	if opts.Groups < 2 || opts.Groups > MaxLocateGroups {
+		fields["locate.groups"] = fmt.Sprintf("must be between 2 and %d", MaxLocateGroups)
+	}
+	if opts.MaxRuns < 1 || opts.MaxRuns > MaxLocateMaxRuns {
+		fields["locate.max_runs"] = fmt.Sprintf("must be between 1 and %d", MaxLocateMaxRuns)
+	}
+	if len(hosts) < 2*minLocateHosts {
+		fields["params.iplist_file"] = fmt.Sprintf("locate needs at least %d hosts", 2*minLocateHosts)
+	}
+	return fields
+}
+
+// locator 定位过程的状态：可疑节点组、已确认正常的节点和结论
+type locator struct {
+	groups  int
+	pending [][]string   // 待继续缩小的可疑节点组（IP 列表条目）
+	good    []string     // 在通过的子运行中出现过的节点（IP 列表条目）
+	plans   []locatePlan // 本轮的测试方式
+	report  LocateReport
+}
+
+// newLocator 以全部节点为可疑节点组开始定位
+func newLocator(hosts []string, groups int) *locator {
+	return &locator{
+		groups:  groups,
+		pending: [][]string{hosts},
+		report:  LocateReport{Suspects: []string{}, Unresolved: [][]string{}, Cleared: []string{}},
+	}
+}
+
+// next 生成下一轮的子运行（不含测试参数），没有可以继续缩小的可疑组时返回 nil
+// 不少于 4 个节点的组拆分为多个子集；更小的组无法再拆成多节点子集，每个节点与一个正常节点配对
+func (l *locator) next(step int) []campaignTest {
+	var tests []campaignTest
+	l.plans = nil
+	partner := 0
+	for _, group := range l.pending {
+		switch {
+		case len(group) >= 2*minLocateHosts:
+			plan := locatePlan{group: group, parts: splitHosts(group, min(l.groups, len(group)/minLocateHosts)), split: true}
+			for i, part := range plan.parts {
+				tests = append(tests, campaignTest{
+					Label: fmt.Sprintf("step %d: group %d/%d (%d hosts)", step, i+1, len(plan.parts), len(part)),
+					Hosts: part,
+				})
+			}
+			l.plans = append(l.plans, plan)
+		case len(l.good) > 0:
+			plan := locatePlan{group: group}
+			for _, host := range group {
+				ref := l.good[partner%len(l.good)]
+				partner++
+				plan.parts = append(plan.parts, []string{host})
+				tests = append(tests, campaignTest{
+					Label: fmt.Sprintf("step %d: %s with %s", step, hostNames([]string{host})[0], hostNames([]string{ref})[0]),
+					Hosts: []string{host, ref},
+				})
+			}
+			l.plans = append(l.plans, plan)
+		default:
+			l.report.Unresolved = append(l.report.Unresolved, hostNames(group))
+		}
+	}
+	l.pending = nil
+	return tests
+}
+
+// apply 根据本轮各子运行是否通过更新可疑节点，passed 与 next 返回的子运行一一对应
+func (l *locator) apply(passed []bool) {
+	i := 0
+	for _, plan := range l.plans {
+		failed := 0
+		for _, part := range plan.parts {
+			ok := passed[i]
+			i++
+			if ok {
+				l.markGood(part)
+				continue
+			}
+			failed++
+			if plan.split {
+				l.pending = append(l.pending, part)
+			} else {
+				l.report.Suspects = append(l.report.Suspects, hostNames(part)...)
+			}
+		}
+		// 所有子集都通过：问题只在这些节点一起运行时出现
+		if failed == 0 {
+			l.report.Unresolved = append(l.report.Unresolved, hostNames(plan.group))
+		}
+	}
+	l.plans = nil
+}
+
+// abandon 放弃本轮的子运行，可疑组记为无法继续缩小
+func (l *locator) abandon() {
+	for _, plan := range l.plans {
+		l.report.Unresolved = append(l.report.Unresolved, hostNames(plan.group))
+	}
+	l.plans = nil
+}
+
+// markGood 记录通过的节点
+func (l *locator) markGood(hosts []string) {
+	for _, host := range hosts {
+		if !slices.Contains(l.good, host) {
+			l.good = append(l.good, host)
+			l.report.Cleared = append(l.report.Cleared, hostNames([]string{host})...)
+		}
+	}
+}
+
+// snapshot 返回当前报告的副本
+func (l *locator) snapshot() *LocateReport {
+	report := l.report
+	report.Suspects = slices.Clone(l.report.Suspects)
+	report.Unresolved = slices.Clone(l.report.Unresolved)
+	report.Cleared = slices.Clone(l.report.Cleared)
+	return &report
+}
+
+// runLocate 定位导致运行变慢或失败的节点：
+//  1. 在全部节点上运行一次，通过时说明问题无法复现，直接结束
+//  2. 把可疑节点分成若干组分别运行，未通过的组继续拆分，各组节点不重叠，同一轮内同时运行
+//  3. 可疑组不足 4 个节点时，把每个节点与已通过的正常节点配对运行，未通过的节点即为问题节点
+func runLocate(c *Campaign) error {
+	opts := *c.info.Request.Locate
+	params := c.info.Request.Params
+	l := newLocator(c.hosts, opts.Groups)
+	budget := opts.MaxRuns
+
+	publish := func() {
+		report := l.snapshot()
+		c.update(func(info *CampaignInfo) { info.LocateReport = report })
+	}
+
+	if !opts.SkipInitial {
+		results, err := c.runTests(0, []campaignTest{{Label: "all hosts", Hosts: c.hosts, Params: params}})
+		if err != nil {
+			return err
+		}
+		budget--
+		if results[0].Run.Passed {
+			l.markGood(c.hosts)
+			l.pending = nil
+			l.report.Conclusion = "all hosts passed; the problem did not reproduce"
+			publish()
+			return nil
+		}
+	}
+
+	for step := 1; ; step++ {
+		tests := l.next(step)
+		if len(tests) == 0 {
+			break
+		}
+		if len(tests) > budget {
+			l.abandon()
+			l.report.Conclusion = fmt.Sprintf("stopped after reaching max_runs %d; ", opts.MaxRuns)
+			break
+		}
+		for i := range tests {
+			tests[i].Params = params
+		}
+
+		results, err := c.runTests(step, tests)
+		if err != nil {
+			publish()
+			return err
+		}
+		budget -= len(tests)
+
+		passed := make([]bool, len(results))
+		for i, result := range results {
+			passed[i] = result.Run.Passed
+		}
+		l.apply(passed)
+		publish()
+	}
+
+	l.report.Conclusion += locateConclusion(&l.report)
+	publish()
+	return nil
+}
+
+// locateConclusion 生成定位结论
+func locateConclusion(report *LocateReport) string {
+	var parts []string
+	if len(report.Suspects) > 0 {
+		parts = append(parts, fmt.Sprintf("isolated %d suspect host(s): %s", len(report.Suspects), strings.Join(report.Suspects, ", ")))
+	}
+	for _, group := range report.Unresolved {
+		parts = append(parts, fmt.Sprintf("could not narrow down further than %s", strings.Join(group, ", ")))
+	}
+	if len(parts) == 0 {
+		return "no suspect host found"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// splitHosts 把节点尽量平均地分成 k 组，保持原有顺序
+func splitHosts(hosts []string, k int) [][]string {
+	if k < 1 {
+		k = 1
+	}
+	groups := make([][]string, 0, k)
+	for i := 0; i < k; i++ {
+		start, end := i*len(hosts)/k, (i+1)*len(hosts)/k
+		groups = append(groups, hosts[start:end])
+	}
+	return groups
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// 节点配对方式
+const (
+	PairwiseAll    = "all"    // 所有节点两两配对，N-1 轮（N 为奇数时 N 轮）
+	PairwiseRing   = "ring"   // 每个节点与环上相邻的节点配对，2 到 3 轮
+	PairwiseRandom = "random" // 每轮随机两两配对，共 rounds 轮
+)
+
+const (
+	// DefaultPairwiseRounds random 配对方式默认的轮数
+	DefaultPairwiseRounds = 3
+	// MaxPairwiseRounds random 配对方式最多的轮数
+	MaxPairwiseRounds = 64
+	// DefaultPairwiseTolerance 节点最好的配对带宽低于参考带宽的比例超过该值时标记为异常（%）
+	DefaultPairwiseTolerance = 10.0
+)
+
+// PairwiseOptions 节点两两带宽测试的参数
+// 子运行使用 params 中的测试参数，建议使用 sendrecv 或 all_reduce，并只测试几个较大的大小
+type PairwiseOptions struct {
+	Schedule  string  `json:"schedule"`  // 配对方式：all（默认）、ring、random
+	Rounds    int     `json:"rounds"`    // random 配对方式的轮数，默认 3
+	Seed      int64   `json:"seed"`      // random 配对方式的随机种子，为 0 时自动生成并记录，用于复现
+	Tolerance float64 `json:"tolerance"` // 标记异常节点的阈值（%），默认 10
+}
+
+// PairwiseReport 节点两两带宽矩阵
+// 矩阵按 hosts 的顺序排列，值为两节点运行的峰值总线带宽（GB/s），对称，对角线、未测试和运行失败的配对为 null
+type PairwiseReport struct {
+	Hosts     []string       `json:"hosts"`
+	Matrix    [][]*float64   `json:"matrix"`
+	Nodes     []PairwiseNode `json:"nodes"`
+	Reference *float64       `json:"reference"` // 各节点最好的配对带宽的中位数，作为正常带宽的参考
+	Outliers  []string       `json:"outliers"`  // 所有配对带宽都明显偏低，或超过一半的配对失败的节点
+	Failed    [][]string     `json:"failed"`    // 运行失败或没有输出数据的配对
+	Pairs     int            `json:"pairs"`     // 计划测试的配对数
+	Tested    int            `json:"tested"`    // 已完成的配对数
+}
+
+// PairwiseNode 一个节点与其他节点配对的带宽汇总
+type PairwiseNode struct {
+	Host   string   `json:"host"`
+	Pairs  int      `json:"pairs"`  // 成功的配对数
+	Failed int      `json:"failed"` // 失败的配对数
+	Min    *float64 `json:"min"`
+	Max    *float64 `json:"max"`
+	Mean   *float64 `json:"mean"`
+	Median *float64 `json:"median"`
+	Low    bool     `json:"low"` // 最好的配对也低于参考带宽超过 tolerance
+}
+
+// validatePairwise 校验节点两两带宽测试的参数并填充默认值
+func validatePairwise(req *CampaignRequest, hosts []string) map[string]string {
+	fields := make(map[string]string)
+	if req.Pairwise == nil {
+		req.Pairwise = &PairwiseOptions{}
+	}
+	opts := req.Pairwise
+	if opts.Schedule == "" {
+		opts.Schedule = PairwiseAll
+	}
+	if opts.Rounds == 0 {
+		opts.Rounds = DefaultPairwiseRounds
+	}
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	if opts.Tolerance == 0 {
+		opts.Tolerance = DefaultPairwiseTolerance
+	}
+
+	switch opts.Schedule {
+	case PairwiseAll, PairwiseRing, PairwiseRandom:
+	default:
+		fields["pairwise.schedule"] = "must be one of all, ring, random"
+	}
+	if opts.Rounds < 1 || opts.Rounds > MaxPairwiseRounds {
+		fields["pairwise.rounds"] = fmt.Sprintf("must be between 1 and %d", MaxPairwiseRounds)
+	}
+	if opts.Tolerance < 0 || opts.Tolerance >= 100 {
+		fields["pairwise.tolerance"] = "must be between 0 and 100"
+	}
+	switch req.Params.Collective {
+	case "", "sendrecv", "all_reduce":
+	default:
+		fields["params.collective"] = "pairwise tests support sendrecv and all_reduce"
+	}
+	if len(hosts) < 2 {
+		fields["params.iplist_file"] = "pairwise tests need at least 2 hosts"
+	}
+	return fields
+}
+
+// pairwiseRounds 按配对方式生成每轮的配对（节点下标），同一轮内的配对不共享节点
+func pairwiseRounds(n int, opts PairwiseOptions) [][][2]int {
+	switch opts.Schedule {
+	case PairwiseRing:
+		return ringRounds(n)
+	case PairwiseRandom:
+		return randomRounds(n, opts.Rounds, opts.Seed)
+	}
+	return roundRobinRounds(n)
+}
+
+// roundRobinRounds 循环赛排法：固定第一个节点，其余节点每轮旋转一位，覆盖所有配对
+func roundRobinRounds(n int) [][][2]int {
+	slots := make([]int, 0, n+1)
+	for i := 0; i < n; i++ {
+		slots = append(slots, i)
+	}
+	if n%2 == 1 {
+		slots = append(slots, -1) // 轮空
+	}
+
+	m := len(slots)
+	var rounds [][][2]int
+	for r := 0; r < m-1; r++ {
+		var round [][2]int
+		for i := 0; i < m/2; i++ {
+			a, b := slots[i], slots[m-1-i]
+			if a >= 0 && b >= 0 {
+				round = append(round, orderedPair(a, b))
+			}
+		}
+		rounds = append(rounds, round)
+
+		// 旋转除第一个以外的位置
+		last := slots[m-1]
+		copy(slots[2:], slots[1:m-1])
+		slots[1] = last
+	}
+	return rounds
+}
+
+// ringRounds 环上相邻节点配对：先配对 (0,1)(2,3)...，再配对 (1,2)(3,4)...，N 为奇数时首尾单独一轮
+func ringRounds(n int) [][][2]int {
+	if n == 2 {
+		return [][][2]int{{{0, 1}}}
+	}
+	var rounds [][][2]int
+	for start := 0; start < 2; start++ {
+		var round [][2]int
+		for i := start; i+1 < n; i += 2 {
+			round = append(round, [2]int{i, i + 1})
+		}
+		if start == 1 && n%2 == 0 {
+			round = append(round, [2]int{0, n - 1})
+		}
+		rounds = append(rounds, round)
+	}
+	if n%2 == 1 {
+		rounds = append(rounds, [][2]int{{0, n - 1}})
+	}
+	return rounds
+}
+
+// randomRounds 每轮随机打乱节点后相邻两两配对，跳过之前轮次已测试过的配对
+func randomRounds(n, count int, seed int64) [][][2]int {
+	rng := rand.New(rand.NewSource(seed))
+	seen := make(map[[2]int]bool)
+	var rounds [][][2]int
+	for r := 0; r < count; r++ {
+		order := rng.Perm(n)
+		var round [][2]int
+		for i := 0; i+1 < n; i += 2 {
+			pair := orderedPair(order[i], order[i+1])
+			if !seen[pair] {
+				seen[pair] = true
+				round = append(round, pair)
+			}
+		}
+		if len(round) > 0 {
+			rounds = append(rounds, round)
+		}
+	}
+	return rounds
+}
+
+// orderedPair 返回较小下标在前的配对
+func orderedPair(a, b int) [2]int {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]int{a, b}
+}
+
+// runPairwise 按配对方式逐轮运行两节点测试，同一轮的配对不共享节点，同时运行
+func runPairwise(c *Campaign) error {
+	opts := *c.info.Request.Pairwise
+	params := c.info.Request.Params
+	names := hostNames(c.hosts)
+	rounds := pairwiseRounds(len(c.hosts), opts)
+
+	values := make(map[[2]int]float64)
+	failed := make(map[[2]int]bool)
+	total := 0
+	for _, round := range rounds {
+		total += len(round)
+	}
+
+	publish := func() {
+		report := buildPairwiseReport(names, values, failed, opts.Tolerance)
+		report.Pairs = total
+		c.update(func(info *CampaignInfo) { info.PairwiseReport = report })
+	}
+	publish()
+
+	for r, round := range rounds {
+		tests := make([]campaignTest, 0, len(round))
+		for _, pair := range round {
+			tests = append(tests, campaignTest{
+				Label:  fmt.Sprintf("round %d/%d: %s - %s", r+1, len(rounds), names[pair[0]], names[pair[1]]),
+				Hosts:  []string{c.hosts[pair[0]], c.hosts[pair[1]]},
+				Params: params,
+			})
+		}
+
+		results, err := c.runTests(r+1, tests)
+		if err != nil {
+			return err
+		}
+		for i, result := range results {
+			// 带宽矩阵只关心运行是否产生了数据，验收阈值不影响矩阵
+			if result.Run.Status == RunStatusSuccess && result.Results.Summary.Points > 0 {
+				values[round[i]] = result.Results.Summary.PeakBusbw
+			} else {
+				failed[round[i]] = true
+			}
+		}
+		publish()
+	}
+	return nil
+}
+
+// buildPairwiseReport 根据已完成的配对生成带宽矩阵和每个节点的汇总
+func buildPairwiseReport(names []string, values map[[2]int]float64, failed map[[2]int]bool, tolerance float64) *PairwiseReport {
+	n := len(names)
+	report := &PairwiseReport{
+		Hosts:    names,
+		Matrix:   make([][]*float64, n),
+		Nodes:    make([]PairwiseNode, n),
+		Outliers: []string{},
+		Failed:   [][]string{},
+		Tested:   len(values) + len(failed),
+	}
+	samples := make([][]float64, n)
+	for i := range report.Matrix {
+		report.Matrix[i] = make([]*float64, n)
+		report.Nodes[i].Host = names[i]
+	}
+	for pair, value := range values {
+		v := value
+		report.Matrix[pair[0]][pair[1]] = &v
+		report.Matrix[pair[1]][pair[0]] = &v
+		samples[pair[0]] = append(samples[pair[0]], value)
+		samples[pair[1]] = append(samples[pair[1]], value)
+	}
+
+	var failedPairs [][2]int
+	for pair := range failed {
+		failedPairs = append(failedPairs, pair)
+		report.Nodes[pair[0]].Failed++
+		report.Nodes[pair[1]].Failed++
+	}
+	sort.Slice(failedPairs, func(i, j int) bool {
+		if failedPairs[i][0] != failedPairs[j][0] {
+			return failedPairs[i][0] < failedPairs[j][0]
+		}
+		return failedPairs[i][1] < failedPairs[j][1]
+	})
+	for _, pair := range failedPairs {
+		report.Failed = append(report.Failed, []string{names[pair[0]], names[pair[1]]})
+	}
+
+	var best []float64
+	for i := range report.Nodes {
+		node := &report.Nodes[i]
+		node.Pairs = len(samples[i])
+		if node.Pairs == 0 {
+			continue
+		}
+		sorted := append([]float64(nil), samples[i]...)
+		sort.Float64s(sorted)
+		sum := 0.0
+		for _, v := range sorted {
+			sum += v
+		}
+		lo, hi, mean, med := sorted[0], sorted[len(sorted)-1], sum/float64(len(sorted)), median(sorted)
+		node.Min, node.Max, node.Mean, node.Median = &lo, &hi, &mean, &med
+		best = append(best, hi)
+	}
+	if len(best) > 0 {
+		sort.Float64s(best)
+		overall := median(best)
+		report.Reference = &overall
+	}
+	for i := range report.Nodes {
+		node := &report.Nodes[i]
+		// 与正常节点配对时带宽正常，只看最好的配对可以避免牵连与问题节点配对的正常节点
+		node.Low = node.Max != nil && *node.Max < *report.Reference*(1-tolerance/100)
+		// Pairs 只统计成功的配对，失败数多于成功数即超过一半
+		if node.Low || node.Failed > node.Pairs {
+			report.Outliers = append(report.Outliers, node.Host)
+		}
+	}
+	return report
+}
+
+// median 返回已排序数据的中位数
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ScalingOptions 扩展性测试的参数
+// 依次在 IP 列表的前 N 个节点上运行 params 中的测试，比较不同节点数下的总线带宽
+type ScalingOptions struct {
+	Nodes []int         `json:"nodes"` // 节点数，默认 2、4、8…直到 IP 列表的全部节点
+	Sizes []interface{} `json:"sizes"` // 报告带宽的测试大小，支持 int 或 string (如 "128M", "1G")，默认为测试的最大大小
+}
+
+// ScalingReport 总线带宽随节点数的变化
+// 理想情况下总线带宽不随节点数变化，效率为相对最少节点数的带宽百分比
+type ScalingReport struct {
+	Sizes  []int          `json:"sizes"`  // 报告带宽的测试大小（字节）
+	Points []ScalingPoint `json:"points"` // 按节点数从小到大排列
+}
+
+// ScalingPoint 一个节点数的运行结果
+type ScalingPoint struct {
+	Nodes          int        `json:"nodes"`
+	Hosts          []string   `json:"hosts"`
+	RunID          string     `json:"run_id,omitempty"`
+	Status         string     `json:"status"` // 运行状态，尚未运行时为空
+	PeakBusbw      *float64   `json:"peak_busbw"`
+	Busbw          []*float64 `json:"busbw"`           // 与 sizes 一一对应，输出中没有该大小时为 null
+	Efficiency     []*float64 `json:"efficiency"`      // 与 sizes 一一对应，相对该大小有数据的最少节点数（%）
+	PeakEfficiency *float64   `json:"peak_efficiency"` // 峰值带宽相对最少节点数的峰值带宽（%）
+}
+
+// validateScaling 校验扩展性测试的参数并填充默认值
+func validateScaling(req *CampaignRequest, hosts []string) map[string]string {
+	fields := make(map[string]string)
+	if req.Scaling == nil {
+		req.Scaling = &ScalingOptions{}
+	}
+	opts := req.Scaling
+	if len(opts.Nodes) == 0 {
+		opts.Nodes = scalingNodes(len(hosts))
+	}
+
+	if len(hosts) < 2 {
+		fields["params.iplist_file"] = "scaling tests need at least 2 hosts"
+	}
+	for _, n := range opts.Nodes {
+		if n < 1 || n > len(hosts) {
+			fields["scaling.nodes"] = fmt.Sprintf("node counts must be between 1 and %d", len(hosts))
+		}
+	}
+	slices.Sort(opts.Nodes)
+	opts.Nodes = slices.Compact(opts.Nodes)
+
+	for i, size := range opts.Sizes {
+		if n, msg := validateSize(size); msg != "" {
+			fields[fmt.Sprintf("scaling.sizes[%d]", i)] = msg
+		} else if n < 0 {
+			fields[fmt.Sprintf("scaling.sizes[%d]", i)] = "must not be empty"
+		}
+	}
+	return fields
+}
+
+// scalingNodes 生成默认的节点数：2 的幂，最后一个为全部节点
+func scalingNodes(total int) []int {
+	var nodes []int
+	for n := 2; n < total; n *= 2 {
+		nodes = append(nodes, n)
+	}
+	return append(nodes, total)
+}
+
+// runScaling 按节点数从小到大依次运行，每次使用 IP 列表的前 N 个节点
+func runScaling(c *Campaign) error {
+	opts := *c.info.Request.Scaling
+	params := c.info.Request.Params
+
+	var sizes []int
+	for _, size := range opts.Sizes {
+		n, _ := validateSize(size)
+		sizes = append(sizes, int(n))
+	}
+
+	var results []campaignResult
+	publish := func() {
+		report := buildScalingReport(opts.Nodes, hostNames(c.hosts), sizes, results)
+		c.update(func(info *CampaignInfo) { info.ScalingReport = report })
+	}
+	publish()
+
+	for i, n := range opts.Nodes {
+		test := campaignTest{
+			Label:  fmt.Sprintf("%d nodes", n),
+			Hosts:  c.hosts[:n],
+			Params: params,
+		}
+		done, err := c.runTests(i+1, []campaignTest{test})
+		if err != nil {
+			return err
+		}
+		results = append(results, done[0])
+		publish()
+	}
+	return nil
+}
+
+// buildScalingReport 汇总已完成的运行，results 与 nodes 的前若干项一一对应
+// sizes 为空时使用已完成运行中最大的测试大小
+func buildScalingReport(nodes []int, names []string, sizes []int, results []campaignResult) *ScalingReport {
+	if len(sizes) == 0 {
+		largest := 0
+		for _, result := range results {
+			largest = max(largest, result.Results.Summary.MaxSize)
+		}
+		if largest > 0 {
+			sizes = []int{largest}
+		}
+	}
+
+	report := &ScalingReport{Sizes: sizes, Points: make([]ScalingPoint, 0, len(nodes))}
+	if report.Sizes == nil {
+		report.Sizes = []int{}
+	}
+	for i, n := range nodes {
+		point := ScalingPoint{
+			Nodes:      n,
+			Hosts:      names[:n],
+			Busbw:      make([]*float64, len(report.Sizes)),
+			Efficiency: make([]*float64, len(report.Sizes)),
+		}
+		if i < len(results) {
+			result := results[i]
+			point.RunID = result.Run.ID
+			point.Status = result.Run.Status
+			if result.Run.Status == RunStatusSuccess && result.Results.Summary.Points > 0 {
+				peak := result.Results.Summary.PeakBusbw
+				point.PeakBusbw = &peak
+				for j, size := range report.Sizes {
+					point.Busbw[j] = busbwAtSize(result.Results.DataPoints, size)
+				}
+			}
+		}
+		report.Points = append(report.Points, point)
+	}
+
+	// 效率以最少节点数中有数据的运行为基准
+	var basePeak *float64
+	base := make([]*float64, len(report.Sizes))
+	for i := range report.Points {
+		point := &report.Points[i]
+		basePeak = scalingEfficiency(basePeak, point.PeakBusbw, &point.PeakEfficiency)
+		for j := range report.Sizes {
+			base[j] = scalingEfficiency(base[j], point.Busbw[j], &point.Efficiency[j])
+		}
+	}
+	return report
+}
+
+// scalingEfficiency 计算 value 相对 base 的百分比写入 out，base 为空时以 value 为基准，返回新的基准
+func scalingEfficiency(base, value *float64, out **float64) *float64 {
+	if value == nil {
+		return base
+	}
+	if base == nil {
+		base = value
+	}
+	if *base > 0 {
+		efficiency := *value / *base * 100
+		*out = &efficiency
+	}
+	return base
+}
+
+// busbwAtSize 返回指定测试大小的总线带宽，取 out-of-place 和 in-place 中较大的值，
+// 有多个数据类型时取最大值，没有该大小时返回 nil
+func busbwAtSize(points []ChartDataPoint, size int) *float64 {
+	var busbw *float64
+	for _, p := range points {
+		if p.Size != size {
+			continue
+		}
+		v := max(p.OutBusbw, p.InBusbw)
+		if busbw == nil || v > *busbw {
+			busbw = &v
+		}
+	}
+	return busbw
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// DefaultSingleNodeTolerance 节点峰值带宽低于所有节点中位数的比例超过该值时标记为异常（%）
+	DefaultSingleNodeTolerance = 10.0
+)
+
+// SingleNodeOptions 单节点测试的参数
+// 每个节点单独运行一次，使用 params 中的测试参数，map_by 决定使用的本地 GPU 数（如 ppr:8:node 使用全部 8 块）
+type SingleNodeOptions struct {
+	Concurrency int     `json:"concurrency"` // 同时运行的节点数，默认与预检查的 SSH 并发数相同
+	Tolerance   float64 `json:"tolerance"`   // 标记异常节点的阈值（%），默认 10
+}
+
+// SingleNodeReport 每个节点单独运行的结果
+type SingleNodeReport struct {
+	Nodes    []SingleNodeResult `json:"nodes"`    // 按 IP 列表的顺序排列，有节点提交失败时不包括之后没有运行的节点
+	Median   *float64           `json:"median"`   // 成功运行的节点峰值总线带宽的中位数（GB/s）
+	Outliers []string           `json:"outliers"` // 提交或运行失败、数据校验出错或带宽明显低于中位数的节点
+	Tested   int                `json:"tested"`
+}
+
+// SingleNodeResult 一个节点的单节点测试结果
+type SingleNodeResult struct {
+	Host        string   `json:"host"`
+	RunID       string   `json:"run_id,omitempty"`
+	Status      string   `json:"status"` // 运行状态，尚未运行时为空
+	PeakBusbw   *float64 `json:"peak_busbw"`
+	Wrong       int      `json:"wrong"`         // #wrong 列的合计
+	OutOfBounds *int     `json:"out_of_bounds"` // nccl-tests 报告的越界值数量，输出中没有时为 null
+	Deviation   *float64 `json:"deviation"`     // 峰值带宽相对中位数的偏差（%）
+	Outlier     bool     `json:"outlier"`
+	Reasons     []string `json:"reasons,omitempty"` // 标记为异常的原因
+}
+
+// validateSingleNode 校验单节点测试的参数并填充默认值
+func validateSingleNode(req *CampaignRequest, hosts []string) map[string]string {
+	fields := make(map[string]string)
+	if req.SingleNode == nil {
+		req.SingleNode = &SingleNodeOptions{}
+	}
+	opts := req.SingleNode
+	if opts.Concurrency == 0 {
+		opts.Concurrency = MaxConcurrency
+	}
+	if opts.Tolerance == 0 {
+		opts.Tolerance = DefaultSingleNodeTolerance
+	}
+
+	if opts.Concurrency < 1 || opts.Concurrency > 1024 {
+		fields["single_node.concurrency"] = "must be between 1 and 1024"
+	}
+	if opts.Tolerance < 0 || opts.Tolerance >= 100 {
+		fields["single_node.tolerance"] = "must be between 0 and 100"
+	}
+	return fields
+}
+
+// runSingleNode 在每个节点上单独运行一次，并发数受 concurrency 限制
+func runSingleNode(c *Campaign) error {
+	opts := *c.info.Request.SingleNode
+	params := c.info.Request.Params
+
+	tests := make([]campaignTest, 0, len(c.hosts))
+	for _, host := range c.hosts {
+		tests = append(tests, campaignTest{
+			Label:  hostNames([]string{host})[0],
+			Hosts:  []string{host},
+			Params: params,
+		})
+	}
+
+	// 提交失败或活动被停止时仍然发布已结束节点的报告，提交失败的节点标记为异常
+	results, err := c.runParallel(1, tests, opts.Concurrency)
+	report := buildSingleNodeReport(results, opts.Tolerance)
+	c.update(func(info *CampaignInfo) { info.SingleNodeReport = report })
+	return err
+}
+
+// buildSingleNodeReport 汇总每个节点的结果，并与所有节点的中位数比较
+func buildSingleNodeReport(results []campaignResult, tolerance float64) *SingleNodeReport {
+	report := &SingleNodeReport{
+		Nodes:    make([]SingleNodeResult, 0, len(results)),
+		Outliers: []string{},
+		Tested:   len(results),
+	}
+
+	var peaks []float64
+	for _, result := range results {
+		summary := result.Results.Summary
+		node := SingleNodeResult{
+			Host:        result.Run.Hosts[0],
+			RunID:       result.Run.ID,
+			Status:      result.Run.Status,
+			Wrong:       summary.Wrong,
+			OutOfBounds: summary.OutOfBounds,
+		}
+		if result.Run.ID == "" {
+			// 提交失败，记录提交时的错误
+			node.Reasons = result.Run.Reasons
+		}
+		if result.Run.Status == RunStatusSuccess && summary.Points > 0 {
+			peak := summary.PeakBusbw
+			node.PeakBusbw = &peak
+			peaks = append(peaks, peak)
+		}
+		report.Nodes = append(report.Nodes, node)
+	}
+
+	if len(peaks) > 0 {
+		sort.Float64s(peaks)
+		med := median(peaks)
+		report.Median = &med
+	}
+
+	for i := range report.Nodes {
+		node := &report.Nodes[i]
+		switch {
+		case node.RunID == "":
+		case node.Status != RunStatusSuccess:
+			node.Reasons = append(node.Reasons, fmt.Sprintf("run %s", node.Status))
+		case node.PeakBusbw == nil:
+			node.Reasons = append(node.Reasons, "no results in output")
+		}
+		if node.Wrong > 0 {
+			node.Reasons = append(node.Reasons, fmt.Sprintf("%d wrong values", node.Wrong))
+		}
+		if node.OutOfBounds != nil && *node.OutOfBounds > 0 {
+			node.Reasons = append(node.Reasons, fmt.Sprintf("%d out of bounds values", *node.OutOfBounds))
+		}
+		if node.PeakBusbw != nil && report.Median != nil && *report.Median > 0 {
+			deviation := (*node.PeakBusbw - *report.Median) / *report.Median * 100
+			node.Deviation = &deviation
+			if deviation < -tolerance {
+				node.Reasons = append(node.Reasons, fmt.Sprintf("peak busbw %.2f GB/s is %.1f%% below the median %.2f GB/s",
+					*node.PeakBusbw, -deviation, *report.Median))
+			}
+		}
+
+		node.Outlier = len(node.Reasons) > 0
+		if node.Outlier {
+			report.Outliers = append(report.Outliers, node.Host)
+		}
+	}
+	return report
+}
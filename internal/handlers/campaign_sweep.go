@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// MaxSweepConfigs 参数扫描最多的组合数
+	MaxSweepConfigs = 256
+)
+
+// sweepFixedFields 不能扫描的参数：节点由活动的 IP 列表决定，环境变量通过 env 扫描
+var sweepFixedFields = map[string]bool{"iplist_file": true, "env": true}
+
+// SweepConfig 一个参数组合：覆盖 params 中的字段和环境变量
+type SweepConfig struct {
+	Fields map[string]interface{} `json:"fields,omitempty"` // 参数字段名（与 params 的 JSON 字段名相同）到取值
+	Env    map[string]string      `json:"env,omitempty"`    // 环境变量，与 params.env 合并，需通过白名单校验
+}
+
+// SweepOptions 参数扫描的参数
+// fields/env 列出每个参数的候选值，展开为所有取值的组合；也可以用 configs 直接指定要运行的组合
+type SweepOptions struct {
+	Fields  map[string][]interface{} `json:"fields,omitempty"`  // 如 {"nccl_min_channels": [16, 32]}
+	Env     map[string][]string      `json:"env,omitempty"`     // 如 {"NCCL_ALGO": ["Ring", "Tree"]}
+	Configs []SweepConfig            `json:"configs,omitempty"` // 要运行的组合，未指定时由 fields/env 展开并记录在这里
+	Sizes   []interface{}            `json:"sizes,omitempty"`   // 排名使用的测试大小，支持 int 或 string (如 "1G")，默认使用峰值带宽
+}
+
+// SweepReport 按带宽排名的参数组合
+type SweepReport struct {
+	Sizes   []int         `json:"sizes"`   // 排名使用的测试大小（字节），为空时按峰值带宽排名
+	Results []SweepResult `json:"results"` // 按得分从高到低排列，没有得分和尚未运行的组合在最后
+	Tested  int           `json:"tested"`
+	Total   int           `json:"total"`
+}
+
+// SweepResult 一个参数组合的运行结果
+type SweepResult struct {
+	Rank      int         `json:"rank,omitempty"` // 从 1 开始，没有得分时为空
+	Index     int         `json:"index"`          // 在 configs 中的下标
+	Label     string      `json:"label"`
+	Config    SweepConfig `json:"config"`
+	RunID     string      `json:"run_id,omitempty"` // 运行 ID，可通过 /history/:id 查看
+	Status    string      `json:"status"`           // 运行状态，尚未运行时为空
+	Passed    bool        `json:"passed"`           // 运行成功且满足验收阈值
+	PeakBusbw *float64    `json:"peak_busbw"`
+	Busbw     []*float64  `json:"busbw"` // 与 sizes 一一对应，输出中没有该大小时为 null
+	Score     *float64    `json:"score"` // sizes 处带宽的平均值，未指定 sizes 时为峰值带宽（GB/s）
+	Reasons   []string    `json:"reasons,omitempty"`
+}
+
+// validateSweep 校验参数扫描的参数，展开 fields/env 的组合并逐个校验合并后的测试参数
+func validateSweep(req *CampaignRequest, hosts []string) map[string]string {
+	fields := make(map[string]string)
+	if req.Sweep == nil {
+		req.Sweep = &SweepOptions{}
+	}
+	opts := req.Sweep
+
+	for i, size := range opts.Sizes {
+		if n, msg := validateSize(size); msg != "" {
+			fields[fmt.Sprintf("sweep.sizes[%d]", i)] = msg
+		} else if n < 0 {
+			fields[fmt.Sprintf("sweep.sizes[%d]", i)] = "must not be empty"
+		}
+	}
+
+	expand := len(opts.Configs) == 0
+	switch {
+	case expand && len(opts.Fields) == 0 && len(opts.Env) == 0:
+		fields["sweep"] = "needs fields, env or configs"
+		return fields
+	case !expand && (len(opts.Fields) > 0 || len(opts.Env) > 0):
+		fields["sweep.configs"] = "cannot be combined with fields or env"
+		return fields
+	}
+
+	if expand {
+		total := 1
+		for name, values := range opts.Fields {
+			if len(values) == 0 {
+				fields["sweep.fields."+name] = "must have at least one value"
+			}
+			total *= max(len(values), 1)
+			total = min(total, MaxSweepConfigs+1)
+		}
+		for name, values := range opts.Env {
+			if len(values) == 0 {
+				fields["sweep.env."+name] = "must have at least one value"
+			}
+			total *= max(len(values), 1)
+			total = min(total, MaxSweepConfigs+1)
+		}
+		if total > MaxSweepConfigs {
+			fields["sweep"] = fmt.Sprintf("expands to more than %d configs", MaxSweepConfigs)
+		}
+		if len(fields) > 0 {
+			return fields
+		}
+		opts.Configs = expandSweep(opts.Fields, opts.Env)
+	} else if len(opts.Configs) > MaxSweepConfigs {
+		fields["sweep.configs"] = fmt.Sprintf("must have at most %d configs", MaxSweepConfigs)
+		return fields
+	}
+
+	for i, config := range opts.Configs {
+		prefix := "sweep."
+		if !expand {
+			prefix = fmt.Sprintf("sweep.configs[%d].", i)
+		}
+		for field, msg := range checkSweepConfig(req.Params, config) {
+			key := "params." + field
+			name, isEnv := strings.CutPrefix(field, "env.")
+			_, swept := config.Fields[field]
+			_, sweptEnv := config.Env[name]
+			switch {
+			case field == "params":
+				key = strings.TrimSuffix(prefix, ".")
+			case swept:
+				key = prefix + "fields." + field
+			case isEnv && sweptEnv:
+				key = prefix + field
+			}
+			// 展开的组合很多，只保留每个字段的第一个错误
+			if _, ok := fields[key]; !ok {
+				fields[key] = fmt.Sprintf("%s (in %s)", msg, sweepLabel(config))
+			}
+		}
+	}
+	return fields
+}
+
+// checkSweepConfig 校验一个组合合并后的测试参数，返回参数字段名到错误信息的映射
+func checkSweepConfig(base NCCLTestParams, config SweepConfig) map[string]string {
+	fields := make(map[string]string)
+	for name, value := range config.Fields {
+		if sweepFixedFields[name] {
+			fields[name] = "cannot be swept"
+			continue
+		}
+		if _, err := applySweepConfig(base, SweepConfig{Fields: map[string]interface{}{name: value}}); err != nil {
+			fields[name] = err.Error()
+		}
+	}
+	if len(fields) > 0 {
+		return fields
+	}
+
+	params, err := applySweepConfig(base, config)
+	if err != nil {
+		fields["params"] = err.Error()
+		return fields
+	}
+	if err := params.Validate(); err != nil {
+		var invalid *ValidationError
+		if errors.As(err, &invalid) {
+			return invalid.Fields
+		}
+		fields["params"] = err.Error()
+	}
+	return fields
+}
+
+// applySweepConfig 把组合覆盖到基础参数上，字段按 JSON 字段名覆盖
+func applySweepConfig(base NCCLTestParams, config SweepConfig) (NCCLTestParams, error) {
+	params := base
+	if len(config.Fields) > 0 {
+		data, err := json.Marshal(base)
+		if err != nil {
+			return base, err
+		}
+		var values map[string]interface{}
+		if err := json.Unmarshal(data, &values); err != nil {
+			return base, err
+		}
+		maps.Copy(values, config.Fields)
+		if data, err = json.Marshal(values); err != nil {
+			return base, err
+		}
+
+		params = NCCLTestParams{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&params); err != nil {
+			if strings.Contains(err.Error(), "unknown field") {
+				return base, fmt.Errorf("unknown parameter")
+			}
+			return base, fmt.Errorf("invalid value: %v", err)
+		}
+	}
+
+	if len(config.Env) > 0 {
+		env := maps.Clone(base.Env)
+		if env == nil {
+			env = make(map[string]string)
+		}
+		maps.Copy(env, config.Env)
+		params.Env = env
+	}
+	return params, nil
+}
+
+// expandSweep 展开所有取值的组合，按名称排序，最后一个名称变化最快
+func expandSweep(fields map[string][]interface{}, env map[string][]string) []SweepConfig {
+	configs := []SweepConfig{{}}
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		var next []SweepConfig
+		for _, config := range configs {
+			for _, value := range fields[name] {
+				c := SweepConfig{Fields: maps.Clone(config.Fields), Env: config.Env}
+				if c.Fields == nil {
+					c.Fields = make(map[string]interface{})
+				}
+				c.Fields[name] = value
+				next = append(next, c)
+			}
+		}
+		configs = next
+	}
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		var next []SweepConfig
+		for _, config := range configs {
+			for _, value := range env[name] {
+				c := SweepConfig{Fields: config.Fields, Env: maps.Clone(config.Env)}
+				if c.Env == nil {
+					c.Env = make(map[string]string)
+				}
+				c.Env[name] = value
+				next = append(next, c)
+			}
+		}
+		configs = next
+	}
+	return configs
+}
+
+// sweepLabel 生成组合的描述，如 nccl_min_channels=16 NCCL_ALGO=Ring
+func sweepLabel(config SweepConfig) string {
+	var parts []string
+	for _, name := range slices.Sorted(maps.Keys(config.Fields)) {
+		parts = append(parts, fmt.Sprintf("%s=%s", name, sizeArg(config.Fields[name])))
+	}
+	for _, name := range slices.Sorted(maps.Keys(config.Env)) {
+		parts = append(parts, name+"="+config.Env[name])
+	}
+	if len(parts) == 0 {
+		return "base params"
+	}
+	return strings.Join(parts, " ")
+}
+
+// runSweep 依次运行每个组合，每次使用活动的全部节点
+func runSweep(c *Campaign) error {
+	opts := *c.info.Request.Sweep
+	base := c.info.Request.Params
+
+	var sizes []int
+	for _, size := range opts.Sizes {
+		n, _ := validateSize(size)
+		sizes = append(sizes, int(n))
+	}
+
+	var results []campaignResult
+	publish := func() {
+		report := buildSweepReport(opts.Configs, sizes, results)
+		c.update(func(info *CampaignInfo) { info.SweepReport = report })
+	}
+	publish()
+
+	for i, config := range opts.Configs {
+		params, err := applySweepConfig(base, config)
+		if err != nil {
+			return fmt.Errorf("config %d: %v", i+1, err)
+		}
+		test := campaignTest{
+			Label:  fmt.Sprintf("config %d/%d: %s", i+1, len(opts.Configs), sweepLabel(config)),
+			Hosts:  c.hosts,
+			Params: params,
+		}
+		done, err := c.runTests(i+1, []campaignTest{test})
+		if err != nil {
+			return err
+		}
+		results = append(results, done[0])
+		publish()
+	}
+	return nil
+}
+
+// buildSweepReport 汇总已完成的组合并按得分排名，results 与 configs 的前若干项一一对应
+func buildSweepReport(configs []SweepConfig, sizes []int, results []campaignResult) *SweepReport {
+	report := &SweepReport{
+		Sizes:   sizes,
+		Results: make([]SweepResult, 0, len(configs)),
+		Tested:  len(results),
+		Total:   len(configs),
+	}
+	if report.Sizes == nil {
+		report.Sizes = []int{}
+	}
+
+	for i, config := range configs {
+		result := SweepResult{
+			Index:  i,
+			Label:  sweepLabel(config),
+			Config: config,
+			Busbw:  make([]*float64, len(report.Sizes)),
+		}
+		if i < len(results) {
+			run := results[i].Run
+			summary := results[i].Results.Summary
+			result.RunID, result.Status, result.Passed = run.ID, run.Status, run.Passed
+			result.Reasons = run.Reasons
+			if run.Status == RunStatusSuccess && summary.Points > 0 {
+				peak := summary.PeakBusbw
+				result.PeakBusbw = &peak
+				result.Score = &peak
+			}
+			if result.PeakBusbw != nil && len(report.Sizes) > 0 {
+				result.Score = nil
+				sum := 0.0
+				for j, size := range report.Sizes {
+					result.Busbw[j] = busbwAtSize(results[i].Results.DataPoints, size)
+					if result.Busbw[j] == nil {
+						result.Reasons = append(result.Reasons, fmt.Sprintf("no result at size %s", formatBytes(size)))
+						sum = -1
+					} else if sum >= 0 {
+						sum += *result.Busbw[j]
+					}
+				}
+				if sum >= 0 {
+					score := sum / float64(len(report.Sizes))
+					result.Score = &score
+				}
+			}
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		a, b := report.Results[i].Score, report.Results[j].Score
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return *a > *b
+	})
+	for i := range report.Results {
+		if report.Results[i].Score != nil {
+			report.Results[i].Rank = i + 1
+		}
+	}
+	return report
+}
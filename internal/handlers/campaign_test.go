@@ -0,0 +1,767 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// locateHosts 生成 n 个节点的 IP 列表条目
+func locateHosts(n int) []string {
+	hosts := make([]string, n)
+	for i := range hosts {
+		hosts[i] = fmt.Sprintf("node%02d slots=8", i+1)
+	}
+	return hosts
+}
+
+func TestLocator(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		hosts      int
+		groups     int
+		bad        []string // 包含任意一个这些节点的子运行不通过
+		together   []string // 同时包含这些节点的子运行不通过
+		suspects   []string
+		unresolved [][]string
+		runs       int
+	}{
+		{
+			desc:     "二分定位一个慢节点",
+			hosts:    8,
+			groups:   2,
+			bad:      []string{"node06"},
+			suspects: []string{"node06"},
+			runs:     2 + 2 + 2, // 8 -> 4 -> 2 -> 与正常节点配对
+		},
+		{
+			desc:     "两个慢节点在不同的组",
+			hosts:    8,
+			groups:   2,
+			bad:      []string{"node02", "node07"},
+			suspects: []string{"node02", "node07"},
+			runs:     2 + 4 + 4,
+		},
+		{
+			desc:     "分成 4 组",
+			hosts:    9,
+			groups:   4,
+			bad:      []string{"node09"},
+			suspects: []string{"node09"},
+			runs:     4 + 3,
+		},
+		{
+			desc:       "只在组合时复现",
+			hosts:      8,
+			groups:     2,
+			together:   []string{"node01", "node08"},
+			unresolved: [][]string{{"node01", "node02", "node03", "node04", "node05", "node06", "node07", "node08"}},
+			runs:       2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			l := newLocator(locateHosts(tc.hosts), tc.groups)
+			runs := 0
+			for step := 1; ; step++ {
+				tests := l.next(step)
+				if len(tests) == 0 {
+					break
+				}
+				runs += len(tests)
+
+				passed := make([]bool, len(tests))
+				for i, test := range tests {
+					names := hostNames(test.Hosts)
+					passed[i] = true
+					for _, host := range tc.bad {
+						if slices.Contains(names, host) {
+							passed[i] = false
+						}
+					}
+					if len(tc.together) > 0 {
+						all := true
+						for _, host := range tc.together {
+							all = all && slices.Contains(names, host)
+						}
+						passed[i] = passed[i] && !all
+					}
+				}
+				l.apply(passed)
+			}
+
+			report := l.snapshot()
+			if !reflect.DeepEqual(report.Suspects, append([]string{}, tc.suspects...)) {
+				t.Errorf("suspects = %v, want %v", report.Suspects, tc.suspects)
+			}
+			if !reflect.DeepEqual(report.Unresolved, append([][]string{}, tc.unresolved...)) {
+				t.Errorf("unresolved = %v, want %v", report.Unresolved, tc.unresolved)
+			}
+			if runs != tc.runs {
+				t.Errorf("runs = %d, want %d", runs, tc.runs)
+			}
+		})
+	}
+}
+
+func TestValidateLocate(t *testing.T) {
+	params := validParams()
+	params.Thresholds = []Threshold{{Metric: "avg_busbw", Op: ">=", Value: 100}}
+
+	testCases := []struct {
+		desc   string
+		params NCCLTestParams
+		opts   *LocateOptions
+		hosts  int
+		field  string
+	}{
+		{desc: "默认参数", params: params, hosts: 4},
+		{desc: "没有阈值", params: validParams(), hosts: 4, field: "params.thresholds"},
+		{desc: "节点太少", params: params, hosts: 3, field: "params.iplist_file"},
+		{desc: "组数太少", params: params, opts: &LocateOptions{Groups: 1}, hosts: 8, field: "locate.groups"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := CampaignRequest{Type: CampaignLocate, Params: tc.params, Locate: tc.opts}
+			fields := validateLocate(&req, locateHosts(tc.hosts))
+			if tc.field == "" && len(fields) > 0 {
+				t.Errorf("unexpected errors: %v", fields)
+			}
+			if tc.field != "" && fields[tc.field] == "" {
+				t.Errorf("expected error on %s, got %v", tc.field, fields)
+			}
+			if req.Locate == nil || req.Locate.MaxRuns == 0 {
+				t.Errorf("defaults should be filled in: %+v", req.Locate)
+			}
+		})
+	}
+}
+
+func TestPairwiseRounds(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		n        int
+		opts     PairwiseOptions
+		rounds   int
+		pairs    int
+		complete bool // 是否覆盖所有配对
+	}{
+		{desc: "全部配对，偶数个节点", n: 8, opts: PairwiseOptions{Schedule: PairwiseAll}, rounds: 7, pairs: 28, complete: true},
+		{desc: "全部配对，奇数个节点", n: 5, opts: PairwiseOptions{Schedule: PairwiseAll}, rounds: 5, pairs: 10, complete: true},
+		{desc: "环，偶数个节点", n: 6, opts: PairwiseOptions{Schedule: PairwiseRing}, rounds: 2, pairs: 6},
+		{desc: "环，奇数个节点", n: 5, opts: PairwiseOptions{Schedule: PairwiseRing}, rounds: 3, pairs: 5},
+		{desc: "两个节点", n: 2, opts: PairwiseOptions{Schedule: PairwiseRing}, rounds: 1, pairs: 1, complete: true},
+		{desc: "随机", n: 8, opts: PairwiseOptions{Schedule: PairwiseRandom, Rounds: 3, Seed: 42}, rounds: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			rounds := pairwiseRounds(tc.n, tc.opts)
+			if len(rounds) != tc.rounds {
+				t.Errorf("rounds = %d, want %d", len(rounds), tc.rounds)
+			}
+
+			seen := make(map[[2]int]bool)
+			for r, round := range rounds {
+				used := make(map[int]bool)
+				for _, pair := range round {
+					if used[pair[0]] || used[pair[1]] || pair[0] >= pair[1] {
+						t.Errorf("round %d has overlapping or unordered pair %v", r, pair)
+					}
+					used[pair[0]], used[pair[1]] = true, true
+					if seen[pair] {
+						t.Errorf("pair %v scheduled twice", pair)
+					}
+					seen[pair] = true
+				}
+			}
+			if tc.pairs > 0 && len(seen) != tc.pairs {
+				t.Errorf("pairs = %d, want %d", len(seen), tc.pairs)
+			}
+			if tc.complete && len(seen) != tc.n*(tc.n-1)/2 {
+				t.Errorf("not all pairs covered: %d", len(seen))
+			}
+		})
+	}
+}
+
+func TestBuildPairwiseReport(t *testing.T) {
+	names := []string{"node01", "node02", "node03", "node04"}
+	values := map[[2]int]float64{}
+	for _, pair := range roundRobinRounds(len(names)) {
+		for _, p := range pair {
+			values[p] = 48
+			if p[0] == 2 || p[1] == 2 {
+				values[p] = 20 // node03 的网卡有问题
+			}
+		}
+	}
+	failed := map[[2]int]bool{{0, 1}: true}
+	delete(values, [2]int{0, 1})
+
+	report := buildPairwiseReport(names, values, failed, DefaultPairwiseTolerance)
+	if !reflect.DeepEqual(report.Outliers, []string{"node03"}) {
+		t.Errorf("outliers = %v, want [node03]", report.Outliers)
+	}
+	if report.Matrix[2][3] == nil || *report.Matrix[2][3] != 20 || report.Matrix[3][2] != report.Matrix[2][3] {
+		t.Errorf("matrix should be symmetric with node03 row at 20: %v", report.Matrix[2])
+	}
+	if report.Matrix[0][1] != nil || report.Matrix[1][1] != nil {
+		t.Errorf("failed pairs and the diagonal should be null")
+	}
+	if !reflect.DeepEqual(report.Failed, [][]string{{"node01", "node02"}}) || report.Tested != 6 {
+		t.Errorf("failed = %v, tested = %d", report.Failed, report.Tested)
+	}
+	if node := report.Nodes[0]; node.Pairs != 2 || node.Failed != 1 || *node.Min != 20 {
+		t.Errorf("unexpected node01 aggregate: %+v", node)
+	}
+}
+
+func TestBuildSingleNodeReport(t *testing.T) {
+	zero, bad := 0, 12
+	result := func(host, status string, peak float64, wrong int, oob *int) campaignResult {
+		points := []ChartDataPoint{comparePoint(1<<30, "float", peak)}
+		summary := summarizeResults(points)
+		summary.Wrong, summary.OutOfBounds = wrong, oob
+		if status != RunStatusSuccess {
+			summary = ResultSummary{}
+		}
+		return campaignResult{
+			Run:     CampaignRun{ID: host + "-run", Hosts: []string{host}, Status: status},
+			Results: RunResults{Summary: summary},
+		}
+	}
+	results := []campaignResult{
+		result("node01", RunStatusSuccess, 480, 0, &zero),
+		result("node02", RunStatusSuccess, 470, 0, &zero),
+		result("node03", RunStatusSuccess, 300, 0, &zero), // NVLink 降级
+		result("node04", RunStatusSuccess, 475, 0, &bad),
+		result("node05", RunStatusError, 0, 0, nil),
+	}
+
+	report := buildSingleNodeReport(results, DefaultSingleNodeTolerance)
+	if report.Median == nil || *report.Median != 472.5 {
+		t.Fatalf("median = %v, want 472.5", report.Median)
+	}
+	if !reflect.DeepEqual(report.Outliers, []string{"node03", "node04", "node05"}) {
+		t.Errorf("outliers = %v", report.Outliers)
+	}
+	for _, node := range report.Nodes {
+		if node.Outlier != (len(node.Reasons) > 0) {
+			t.Errorf("%s: outlier = %v with reasons %v", node.Host, node.Outlier, node.Reasons)
+		}
+	}
+	if d := report.Nodes[2].Deviation; d == nil || *d > -30 {
+		t.Errorf("node03 deviation = %v", d)
+	}
+}
+
+func TestValidateScaling(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		opts  *ScalingOptions
+		hosts int
+		nodes []int
+		field string
+	}{
+		{desc: "默认节点数", hosts: 12, nodes: []int{2, 4, 8, 12}},
+		{desc: "节点数为 2 的幂", hosts: 8, nodes: []int{2, 4, 8}},
+		{desc: "排序并去重", opts: &ScalingOptions{Nodes: []int{4, 2, 4}}, hosts: 8, nodes: []int{2, 4}},
+		{desc: "节点数超过 IP 列表", opts: &ScalingOptions{Nodes: []int{2, 16}}, hosts: 8, field: "scaling.nodes"},
+		{desc: "无效的大小", opts: &ScalingOptions{Sizes: []interface{}{"1G", "1X"}}, hosts: 8, field: "scaling.sizes[1]"},
+		{desc: "节点太少", hosts: 1, field: "params.iplist_file"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := CampaignRequest{Type: CampaignScaling, Params: validParams(), Scaling: tc.opts}
+			fields := validateScaling(&req, locateHosts(tc.hosts))
+			if tc.field == "" && len(fields) > 0 {
+				t.Errorf("unexpected errors: %v", fields)
+			}
+			if tc.field != "" && fields[tc.field] == "" {
+				t.Errorf("expected error on %s, got %v", tc.field, fields)
+			}
+			if tc.nodes != nil && !reflect.DeepEqual(req.Scaling.Nodes, tc.nodes) {
+				t.Errorf("nodes = %v, want %v", req.Scaling.Nodes, tc.nodes)
+			}
+		})
+	}
+}
+
+func TestBuildScalingReport(t *testing.T) {
+	result := func(status string, busbw ...float64) campaignResult {
+		var points []ChartDataPoint
+		for i, v := range busbw {
+			points = append(points, comparePoint(1<<(27+i), "float", v))
+		}
+		return campaignResult{
+			Run:     CampaignRun{ID: fmt.Sprintf("run-%d", len(busbw)), Status: status},
+			Results: RunResults{DataPoints: points, Summary: summarizeResults(points)},
+		}
+	}
+	names := []string{"node01", "node02", "node03", "node04", "node05", "node06", "node07", "node08"}
+	nodes := []int{2, 4, 8}
+
+	testCases := []struct {
+		desc       string
+		sizes      []int
+		results    []campaignResult
+		wantSizes  []int
+		busbw      [][]float64 // 每个节点数在各大小的带宽，-1 表示 null
+		efficiency [][]float64
+	}{
+		{
+			desc:       "默认使用最大的大小",
+			results:    []campaignResult{result(RunStatusSuccess, 100, 200), result(RunStatusSuccess, 90, 180), result(RunStatusSuccess, 80, 150)},
+			wantSizes:  []int{1 << 28},
+			busbw:      [][]float64{{200}, {180}, {150}},
+			efficiency: [][]float64{{100}, {90}, {75}},
+		},
+		{
+			desc:       "指定大小，最少节点数运行失败时以下一个为基准",
+			sizes:      []int{1 << 27, 1 << 30},
+			results:    []campaignResult{result(RunStatusError), result(RunStatusSuccess, 100, 200), result(RunStatusSuccess, 50, 150)},
+			wantSizes:  []int{1 << 27, 1 << 30},
+			busbw:      [][]float64{{-1, -1}, {100, -1}, {50, -1}},
+			efficiency: [][]float64{{-1, -1}, {100, -1}, {50, -1}},
+		},
+		{
+			desc:       "尚未完成的节点数",
+			results:    []campaignResult{result(RunStatusSuccess, 100)},
+			wantSizes:  []int{1 << 27},
+			busbw:      [][]float64{{100}, {-1}, {-1}},
+			efficiency: [][]float64{{100}, {-1}, {-1}},
+		},
+	}
+
+	values := func(ptrs []*float64) []float64 {
+		out := make([]float64, len(ptrs))
+		for i, p := range ptrs {
+			out[i] = -1
+			if p != nil {
+				out[i] = *p
+			}
+		}
+		return out
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			report := buildScalingReport(nodes, names, tc.sizes, tc.results)
+			if !reflect.DeepEqual(report.Sizes, tc.wantSizes) {
+				t.Fatalf("sizes = %v, want %v", report.Sizes, tc.wantSizes)
+			}
+			for i, point := range report.Points {
+				if point.Nodes != nodes[i] || len(point.Hosts) != nodes[i] {
+					t.Errorf("point %d: nodes = %d, hosts = %v", i, point.Nodes, point.Hosts)
+				}
+				if got := values(point.Busbw); !reflect.DeepEqual(got, tc.busbw[i]) {
+					t.Errorf("%d nodes: busbw = %v, want %v", point.Nodes, got, tc.busbw[i])
+				}
+				if got := values(point.Efficiency); !reflect.DeepEqual(got, tc.efficiency[i]) {
+					t.Errorf("%d nodes: efficiency = %v, want %v", point.Nodes, got, tc.efficiency[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSweep(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		opts    *SweepOptions
+		configs []string // 展开后每个组合的描述
+		field   string
+	}{
+		{
+			desc: "展开字段和环境变量的组合",
+			opts: &SweepOptions{
+				Fields: map[string][]interface{}{"nccl_min_channels": {float64(16), float64(32)}, "datatype": {"float"}},
+				Env:    map[string][]string{"NCCL_ALGO": {"Ring", "Tree"}},
+			},
+			configs: []string{
+				"datatype=float nccl_min_channels=16 NCCL_ALGO=Ring",
+				"datatype=float nccl_min_channels=16 NCCL_ALGO=Tree",
+				"datatype=float nccl_min_channels=32 NCCL_ALGO=Ring",
+				"datatype=float nccl_min_channels=32 NCCL_ALGO=Tree",
+			},
+		},
+		{
+			desc:    "指定组合",
+			opts:    &SweepOptions{Configs: []SweepConfig{{Env: map[string]string{"NCCL_PROTO": "Simple"}}, {}}},
+			configs: []string{"NCCL_PROTO=Simple", "base params"},
+		},
+		{desc: "没有扫描的参数", opts: &SweepOptions{}, field: "sweep"},
+		{desc: "未知字段", opts: &SweepOptions{Fields: map[string][]interface{}{"channels": {float64(4)}}}, field: "sweep.fields.channels"},
+		{desc: "不能扫描 IP 列表", opts: &SweepOptions{Fields: map[string][]interface{}{"iplist_file": {"a"}}}, field: "sweep.fields.iplist_file"},
+		{desc: "字段值无效", opts: &SweepOptions{Fields: map[string][]interface{}{"nccl_min_channels": {"many"}}}, field: "sweep.fields.nccl_min_channels"},
+		{desc: "校验合并后的参数", opts: &SweepOptions{Fields: map[string][]interface{}{"datatype": {"float", "int4"}}}, field: "sweep.fields.datatype"},
+		{desc: "环境变量不在白名单", opts: &SweepOptions{Configs: []SweepConfig{{}, {Env: map[string]string{"LD_PRELOAD": "x"}}}}, field: "sweep.configs[1].env.LD_PRELOAD"},
+		{
+			desc:  "组合太多",
+			opts:  &SweepOptions{Fields: map[string][]interface{}{"iters": make([]interface{}, 20), "nccl_min_channels": make([]interface{}, 20)}},
+			field: "sweep",
+		},
+		{desc: "同时指定组合和字段", opts: &SweepOptions{Configs: []SweepConfig{{}}, Env: map[string][]string{"NCCL_ALGO": {"Ring"}}}, field: "sweep.configs"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := CampaignRequest{Type: CampaignSweep, Params: validParams(), Sweep: tc.opts}
+			fields := validateSweep(&req, locateHosts(2))
+			if tc.field == "" && len(fields) > 0 {
+				t.Errorf("unexpected errors: %v", fields)
+			}
+			if tc.field != "" && fields[tc.field] == "" {
+				t.Errorf("expected error on %s, got %v", tc.field, fields)
+			}
+			if tc.configs == nil {
+				return
+			}
+			var labels []string
+			for _, config := range req.Sweep.Configs {
+				labels = append(labels, sweepLabel(config))
+			}
+			if !reflect.DeepEqual(labels, tc.configs) {
+				t.Errorf("configs = %q, want %q", labels, tc.configs)
+			}
+		})
+	}
+}
+
+func TestApplySweepConfig(t *testing.T) {
+	base := validParams()
+	base.Env = map[string]string{"NCCL_ALGO": "Ring"}
+	params, err := applySweepConfig(base, SweepConfig{
+		Fields: map[string]interface{}{"nccl_min_channels": float64(4), "test_size_end": "8G"},
+		Env:    map[string]string{"NCCL_PROTO": "LL128"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.NCCLMinChannels != 4 || params.TestSizeEnd != "8G" || params.MapBy != base.MapBy {
+		t.Errorf("fields not applied: %+v", params)
+	}
+	if !reflect.DeepEqual(params.Env, map[string]string{"NCCL_ALGO": "Ring", "NCCL_PROTO": "LL128"}) {
+		t.Errorf("env = %v", params.Env)
+	}
+	if len(base.Env) != 1 {
+		t.Errorf("base env modified: %v", base.Env)
+	}
+}
+
+func TestBuildSweepReport(t *testing.T) {
+	result := func(id, status string, busbw ...float64) campaignResult {
+		var points []ChartDataPoint
+		for i, v := range busbw {
+			points = append(points, comparePoint(1<<(29+i), "float", v))
+		}
+		return campaignResult{
+			Run:     CampaignRun{ID: id, Status: status, Passed: status == RunStatusSuccess},
+			Results: RunResults{DataPoints: points, Summary: summarizeResults(points)},
+		}
+	}
+	configs := []SweepConfig{
+		{Env: map[string]string{"NCCL_ALGO": "Ring"}},
+		{Env: map[string]string{"NCCL_ALGO": "Tree"}},
+		{Env: map[string]string{"NCCL_ALGO": "CollnetDirect"}},
+		{Env: map[string]string{"NCCL_ALGO": "NVLS"}},
+		{Env: map[string]string{"NCCL_ALGO": "PAT"}},
+	}
+	results := []campaignResult{
+		result("ring", RunStatusSuccess, 100, 200),
+		result("tree", RunStatusSuccess, 150, 170),
+		result("collnet", RunStatusError),
+		result("nvls", RunStatusSuccess, 120),
+	}
+
+	testCases := []struct {
+		desc  string
+		sizes []int
+		order []string // 排名后的运行 ID，尚未运行的为空
+		ranks []int
+	}{
+		{desc: "按峰值带宽排名", order: []string{"ring", "tree", "nvls", "collnet", ""}, ranks: []int{1, 2, 3, 0, 0}},
+		{desc: "按指定大小的平均带宽排名", sizes: []int{1 << 29, 1 << 30}, order: []string{"tree", "ring", "collnet", "nvls", ""}, ranks: []int{1, 2, 0, 0, 0}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			report := buildSweepReport(configs, tc.sizes, results)
+			if report.Tested != 4 || report.Total != 5 {
+				t.Errorf("tested = %d, total = %d", report.Tested, report.Total)
+			}
+			var order []string
+			var ranks []int
+			for _, r := range report.Results {
+				order = append(order, r.RunID)
+				ranks = append(ranks, r.Rank)
+			}
+			if !reflect.DeepEqual(order, tc.order) || !reflect.DeepEqual(ranks, tc.ranks) {
+				t.Errorf("order = %q ranks = %v, want %q %v", order, ranks, tc.order, tc.ranks)
+			}
+		})
+	}
+}
+
+func TestPairwiseFailedOutliers(t *testing.T) {
+	names := []string{"node01", "node02", "node03", "node04"}
+	testCases := []struct {
+		desc     string
+		values   map[[2]int]float64
+		failed   map[[2]int]bool
+		outliers []string
+	}{
+		{
+			desc:     "一半配对失败不标记",
+			values:   map[[2]int]float64{{0, 1}: 48, {2, 3}: 48},
+			failed:   map[[2]int]bool{{0, 2}: true, {1, 3}: true},
+			outliers: []string{},
+		},
+		{
+			desc:     "超过一半配对失败",
+			values:   map[[2]int]float64{{0, 1}: 48, {0, 2}: 48, {1, 2}: 48},
+			failed:   map[[2]int]bool{{0, 3}: true, {1, 3}: true, {2, 3}: true},
+			outliers: []string{"node04"},
+		},
+		{
+			desc:     "全部配对失败",
+			values:   map[[2]int]float64{},
+			failed:   map[[2]int]bool{{0, 1}: true, {2, 3}: true},
+			outliers: []string{"node01", "node02", "node03", "node04"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			report := buildPairwiseReport(names, tc.values, tc.failed, DefaultPairwiseTolerance)
+			if !reflect.DeepEqual(report.Outliers, tc.outliers) {
+				t.Errorf("outliers = %v, want %v", report.Outliers, tc.outliers)
+			}
+		})
+	}
+}
+
+// failingLauncher 测试用启动器：节点名以 fail 开头时稍后提交失败，其他节点使用模拟启动器
+// 延迟使同时提交的其他子运行在失败之前开始
+type failingLauncher struct {
+	FakeLauncher
+}
+
+func (l *failingLauncher) Name() string {
+	return "fake-failing"
+}
+
+func (l *failingLauncher) Prepare(params NCCLTestParams, hosts []string, workDir string) (*LaunchSpec, error) {
+	for _, host := range hostNames(hosts) {
+		if strings.HasPrefix(host, "fail") {
+			time.Sleep(100 * time.Millisecond)
+			return nil, fmt.Errorf("cannot reach %s", host)
+		}
+	}
+	return l.FakeLauncher.Prepare(params, hosts, workDir)
+}
+
+func init() {
+	RegisterLauncher(&failingLauncher{})
+}
+
+// campaignParams 活动子运行的测试参数：每个节点一个进程、只测一个大小，模拟输出较短
+func campaignParams() NCCLTestParams {
+	params := validParams()
+	params.MapBy = "ppr:1:node"
+	params.TestSizeBegin = "1M"
+	params.TestSizeEnd = "1M"
+	params.Launcher = "fake-failing"
+	return params
+}
+
+// newTestCampaignManager 创建活动注册表，IP 列表文件 test 包含 hosts，子运行使用临时数据目录中的测试运行注册表
+func newTestCampaignManager(t *testing.T, hosts ...string) (*CampaignManager, *RunManager) {
+	t.Helper()
+	runs := newTestRunManager(t)
+	oldRuns := defaultRunManager
+	defaultRunManager = runs
+	t.Cleanup(func() { defaultRunManager = oldRuns })
+
+	if err := os.MkdirAll(filepath.Join(DataDir, IPListDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(DataDir, IPListDir, "test"), []byte(strings.Join(hosts, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return NewCampaignManager(), runs
+}
+
+// submitTestCampaign 使用 IP 列表文件 test 提交活动，测试结束时停止未结束的活动
+func submitTestCampaign(t *testing.T, m *CampaignManager, req CampaignRequest) *Campaign {
+	t.Helper()
+	req.Params.IPListFile = "test"
+	req.Owner = "alice"
+
+	c, err := m.Submit(req)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	t.Cleanup(func() {
+		if !c.Finished() {
+			m.Stop(c)
+		}
+		<-c.Done()
+	})
+	return c
+}
+
+// waitCampaign 等待活动结束
+func waitCampaign(t *testing.T, c *Campaign) CampaignInfo {
+	t.Helper()
+	select {
+	case <-c.Done():
+	case <-time.After(60 * time.Second):
+		t.Fatalf("campaign %s did not finish", c.Info().ID)
+	}
+	return c.Info()
+}
+
+func TestRunSingleNodeSubmitFailure(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		hosts       []string
+		concurrency int
+		tested      int
+		outliers    []string
+	}{
+		{"提交失败时仍发布已结束节点的报告", []string{"node01", "node02", "fail03"}, 3, 3, []string{"fail03"}},
+		{"提交失败后不再提交新的子运行", []string{"fail01", "fail02", "fail03", "fail04"}, 1, 1, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			m, _ := newTestCampaignManager(t, tc.hosts...)
+			c := submitTestCampaign(t, m, CampaignRequest{
+				Type:       CampaignSingleNode,
+				Params:     campaignParams(),
+				SingleNode: &SingleNodeOptions{Concurrency: tc.concurrency},
+			})
+			info := waitCampaign(t, c)
+
+			if info.Status != CampaignStatusError || !strings.Contains(info.Error, "cannot reach fail") {
+				t.Errorf("status = %s, error = %q", info.Status, info.Error)
+			}
+			report := info.SingleNodeReport
+			if report == nil {
+				t.Fatal("report should be published")
+			}
+			if report.Tested != tc.tested || len(report.Outliers) != 1 {
+				t.Fatalf("tested = %d, outliers = %v", report.Tested, report.Outliers)
+			}
+			if tc.outliers != nil && !reflect.DeepEqual(report.Outliers, tc.outliers) {
+				t.Errorf("outliers = %v, want %v", report.Outliers, tc.outliers)
+			}
+			for _, node := range report.Nodes {
+				if !strings.HasPrefix(node.Host, "fail") && (node.Outlier || node.PeakBusbw == nil) {
+					t.Errorf("node %s should pass: %+v", node.Host, node)
+				}
+				if strings.HasPrefix(node.Host, "fail") && (node.RunID != "" || !slices.Equal(node.Reasons, []string{"failed to submit " + node.Host + ": cannot reach " + node.Host})) {
+					t.Errorf("node %s should record the submit error: %+v", node.Host, node)
+				}
+			}
+		})
+	}
+}
+
+func TestRunLocate(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		maxRuns    int
+		suspects   []string
+		conclusion string
+	}{
+		{"定位慢节点", 0, []string{"node06"}, "isolated 1 suspect host(s): node06"},
+		{"达到子运行上限", 3, []string{}, "stopped after reaching max_runs 3"},
+	}
+
+	hosts := make([]string, 8)
+	for i := range hosts {
+		hosts[i] = fmt.Sprintf("node%02d", i+1)
+	}
+	params := campaignParams()
+	params.TestSizeBegin = "1G"
+	params.TestSizeEnd = "1G"
+	params.Thresholds = []Threshold{{Metric: "peak_busbw", Op: ">=", Value: 300}}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			m, _ := newTestCampaignManager(t, hosts...)
+			// node06 的带宽只有其他节点的一半
+			if err := os.WriteFile(filepath.Join(DataDir, FakeHostsFile), []byte(`{"node06": 0.5}`), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			c := submitTestCampaign(t, m, CampaignRequest{
+				Type:   CampaignLocate,
+				Params: params,
+				Locate: &LocateOptions{MaxRuns: tc.maxRuns},
+			})
+			info := waitCampaign(t, c)
+
+			maxRuns := info.Request.Locate.MaxRuns
+			if info.Status != CampaignStatusSuccess || len(info.Runs) == 0 || len(info.Runs) > maxRuns {
+				t.Fatalf("status = %s, runs = %d, max_runs = %d, error = %s", info.Status, len(info.Runs), maxRuns, info.Error)
+			}
+			report := info.LocateReport
+			if !reflect.DeepEqual(report.Suspects, tc.suspects) || !strings.Contains(report.Conclusion, tc.conclusion) {
+				t.Errorf("suspects = %v, conclusion = %q", report.Suspects, report.Conclusion)
+			}
+			// 包含 node06 的子运行都未通过，其余都通过
+			for _, run := range info.Runs {
+				if slow := slices.Contains(run.Hosts, "node06"); run.Passed == slow {
+					t.Errorf("run %s on %v: passed = %v", run.Label, run.Hosts, run.Passed)
+				}
+			}
+		})
+	}
+}
+
+func TestStopCampaign(t *testing.T) {
+	m, runs := newTestCampaignManager(t, "node01", "node02", "node03", "node04")
+	params := campaignParams()
+	params.Thresholds = []Threshold{{Metric: "peak_busbw", Op: ">=", Value: 1e6}}
+	c := submitTestCampaign(t, m, CampaignRequest{Type: CampaignLocate, Params: params})
+
+	// 等待第一个子运行开始后停止活动
+	deadline := time.Now().Add(10 * time.Second)
+	for len(runs.Active()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("campaign did not submit a run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := m.Stop(c); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	info := waitCampaign(t, c)
+
+	if info.Status != CampaignStatusStopped {
+		t.Errorf("status = %s, want stopped", info.Status)
+	}
+	// 进行中的子运行被停止，之后不再提交新的子运行
+	all := runs.List()
+	if len(all) != 1 || len(info.Runs) != 1 {
+		t.Fatalf("runs = %d, campaign runs = %d, want 1", len(all), len(info.Runs))
+	}
+	if status := all[0].Info().Status; status != RunStatusStopped || info.Runs[0].Status != RunStatusStopped {
+		t.Errorf("run status = %s, campaign run status = %s", status, info.Runs[0].Status)
+	}
+	if err := m.Stop(c); err == nil {
+		t.Error("stopping a finished campaign should fail")
+	}
+}
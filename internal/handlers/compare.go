@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultCompareTolerance 默认容差（百分比），差异超过容差的大小会被标记
+	DefaultCompareTolerance = 5.0
+	// MaxCompareRuns 一次最多对比的运行数量
+	MaxCompareRuns = 10
+)
+
+// compareMetrics 参与对比的指标，time 越小越好，其余越大越好
+var compareMetrics = []struct {
+	Name  string
+	Value func(p ChartDataPoint) float64
+}{
+	{"out_time", func(p ChartDataPoint) float64 { return p.OutTime }},
+	{"out_algbw", func(p ChartDataPoint) float64 { return p.OutAlgbw }},
+	{"out_busbw", func(p ChartDataPoint) float64 { return p.OutBusbw }},
+	{"in_time", func(p ChartDataPoint) float64 { return p.InTime }},
+	{"in_algbw", func(p ChartDataPoint) float64 { return p.InAlgbw }},
+	{"in_busbw", func(p ChartDataPoint) float64 { return p.InBusbw }},
+}
+
+// CompareRun 参与对比的运行概况
+type CompareRun struct {
+	ID          string        `json:"id"`
+	Status      string        `json:"status,omitempty"`
+	Collective  string        `json:"collective,omitempty"`
+	Datatypes   []string      `json:"datatypes"`
+	Hosts       int           `json:"hosts,omitempty"`
+	WorldSize   int           `json:"world_size,omitempty"`
+	NCCLVersion string        `json:"nccl_version,omitempty"`
+	CUDAVersion string        `json:"cuda_version,omitempty"`
+	Topology    []string      `json:"topology_issues,omitempty"`
+	Summary     ResultSummary `json:"summary"`
+}
+
+// MetricDelta 单个指标的差异，Pct 相对于基准运行，基准值为 0 时为 null
+type MetricDelta struct {
+	Baseline float64  `json:"baseline"`
+	Value    float64  `json:"value"`
+	Abs      float64  `json:"abs"`
+	Pct      *float64 `json:"pct"`
+}
+
+// SizeComparison 同一测试大小的对比结果
+type SizeComparison struct {
+	Size     int                    `json:"size"`
+	Type     string                 `json:"type"`
+	Deltas   map[string]MetricDelta `json:"deltas"`
+	Exceeded []string               `json:"exceeded,omitempty"` // 差异超过容差的指标
+}
+
+// RunComparison 一个运行与基准运行的对比结果
+type RunComparison struct {
+	Run               string           `json:"run"`
+	Baseline          string           `json:"baseline"`
+	Sizes             []SizeComparison `json:"sizes"`
+	ExceededSizes     int              `json:"exceeded_sizes"`      // 有指标超过容差的大小数量
+	MissingInRun      []int            `json:"missing_in_run"`      // 只在基准运行中出现的大小
+	MissingInBaseline []int            `json:"missing_in_baseline"` // 只在本运行中出现的大小
+	AvgBusbw          *MetricDelta     `json:"avg_busbw,omitempty"` // 报告的平均总线带宽差异
+	PeakBusbw         MetricDelta      `json:"peak_busbw"`          // 峰值总线带宽差异
+}
+
+// CompareResponse 对比结果，第一个运行作为基准
+type CompareResponse struct {
+	Baseline    string          `json:"baseline"`
+	Tolerance   float64         `json:"tolerance"` // 百分比
+	Runs        []CompareRun    `json:"runs"`
+	Comparisons []RunComparison `json:"comparisons"`
+	Warnings    []string        `json:"warnings"`
+}
+
+// 读取对比运行时的错误
+var (
+	errCompareRunNotFound    = errors.New("run not found")
+	errCompareRunNotFinished = errors.New("run has not finished")
+)
+
+// compareSource 对比使用的结果和元数据，旧版历史记录没有元数据
+type compareSource struct {
+	results RunResults
+	meta    *HistoryMeta
+}
+
+// loadCompareSource 读取运行的结果：优先使用历史记录，尚未保存的已结束运行从内存中解析
+func loadCompareSource(id string) (compareSource, error) {
+	results, err := loadHistoryResults(id)
+	if err == nil {
+		meta, err := loadHistoryMeta(id)
+		if err != nil {
+			return compareSource{}, err
+		}
+		return compareSource{results: results, meta: meta}, nil
+	}
+	if !os.IsNotExist(err) {
+		return compareSource{}, err
+	}
+
+	run, ok := defaultRunManager.Get(id)
+	if !ok {
+		return compareSource{}, errCompareRunNotFound
+	}
+	if !run.Finished() {
+		return compareSource{}, errCompareRunNotFinished
+	}
+	meta := newHistoryMeta(run.Info(), run.spec)
+	results = ParseRunResults(id, run.Log().String())
+	results.Topology = CheckTopology(results.Devices, meta.Hosts, meta.Params)
+	return compareSource{
+		results: results,
+		meta:    &meta,
+	}, nil
+}
+
+// CompareRuns 以第一个运行为基准，按测试大小对齐后计算各指标的差异
+func CompareRuns(sources []compareSource, tolerance float64) CompareResponse {
+	resp := CompareResponse{
+		Tolerance:   tolerance,
+		Runs:        make([]CompareRun, 0, len(sources)),
+		Comparisons: []RunComparison{},
+		Warnings:    []string{},
+	}
+	if len(sources) == 0 {
+		return resp
+	}
+
+	for _, src := range sources {
+		resp.Runs = append(resp.Runs, compareRunInfo(src))
+	}
+	base := sources[0]
+	baseRun := resp.Runs[0]
+	resp.Baseline = baseRun.ID
+
+	if len(base.results.DataPoints) == 0 {
+		resp.Warnings = append(resp.Warnings, fmt.Sprintf("baseline %s has no data points", baseRun.ID))
+	}
+	if baseRun.Status != "" && baseRun.Status != RunStatusSuccess {
+		resp.Warnings = append(resp.Warnings, fmt.Sprintf("baseline %s finished with status %s", baseRun.ID, baseRun.Status))
+	}
+
+	for i, src := range sources[1:] {
+		run := resp.Runs[i+1]
+		resp.Warnings = append(resp.Warnings, compareWarnings(baseRun, run)...)
+
+		// 数据类型一致时按 (类型, 大小) 对齐，否则只按大小对齐
+		byType := strings.Join(baseRun.Datatypes, ",") == strings.Join(run.Datatypes, ",")
+		cmp := compareSeries(base.results.DataPoints, src.results.DataPoints, byType, tolerance)
+		cmp.Run = run.ID
+		cmp.Baseline = baseRun.ID
+		cmp.PeakBusbw = newMetricDelta(baseRun.Summary.PeakBusbw, run.Summary.PeakBusbw)
+		if baseRun.Summary.AvgBusbw != nil && run.Summary.AvgBusbw != nil {
+			delta := newMetricDelta(*baseRun.Summary.AvgBusbw, *run.Summary.AvgBusbw)
+			cmp.AvgBusbw = &delta
+		}
+
+		if len(cmp.MissingInRun) > 0 || len(cmp.MissingInBaseline) > 0 {
+			resp.Warnings = append(resp.Warnings, fmt.Sprintf(
+				"size range of %s (%s-%s) differs from baseline %s (%s-%s): %d sizes only in baseline, %d only in %s",
+				run.ID, formatBytes(run.Summary.MinSize), formatBytes(run.Summary.MaxSize),
+				baseRun.ID, formatBytes(baseRun.Summary.MinSize), formatBytes(baseRun.Summary.MaxSize),
+				len(cmp.MissingInRun), len(cmp.MissingInBaseline), run.ID))
+		}
+		resp.Comparisons = append(resp.Comparisons, cmp)
+	}
+
+	return resp
+}
+
+// compareRunInfo 生成参与对比的运行概况
+func compareRunInfo(src compareSource) CompareRun {
+	run := CompareRun{
+		ID:        src.results.ID,
+		Datatypes: []string{},
+		Summary:   src.results.Summary,
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range src.results.DataPoints {
+		if !seen[p.Type] {
+			seen[p.Type] = true
+			run.Datatypes = append(run.Datatypes, p.Type)
+		}
+	}
+	sort.Strings(run.Datatypes)
+
+	if src.meta != nil {
+		run.Status = src.meta.Status
+		run.Collective = src.meta.Params.Collective
+		run.Hosts = len(src.meta.Hosts)
+	}
+	if h := src.results.Header; h != nil {
+		if h.Collective != "" {
+			run.Collective = h.Collective
+		}
+		run.WorldSize = h.WorldSize
+		run.NCCLVersion = h.NCCLVersion
+		run.CUDAVersion = h.CUDAVersion
+	}
+	run.Topology = src.results.Topology.Messages()
+	return run
+}
+
+// compareWarnings 检查运行与基准运行的测试条件是否一致
+func compareWarnings(base, run CompareRun) []string {
+	var warnings []string
+
+	if run.Summary.Points == 0 {
+		warnings = append(warnings, fmt.Sprintf("%s has no data points", run.ID))
+	}
+	if run.Status != "" && run.Status != RunStatusSuccess {
+		warnings = append(warnings, fmt.Sprintf("%s finished with status %s", run.ID, run.Status))
+	}
+	if base.Summary.Points > 0 && run.Summary.Points > 0 &&
+		strings.Join(base.Datatypes, ",") != strings.Join(run.Datatypes, ",") {
+		warnings = append(warnings, fmt.Sprintf("datatype of %s (%s) differs from baseline %s (%s); sizes are aligned ignoring datatype",
+			run.ID, strings.Join(run.Datatypes, ","), base.ID, strings.Join(base.Datatypes, ",")))
+	}
+	if base.Collective != "" && run.Collective != "" && base.Collective != run.Collective {
+		warnings = append(warnings, fmt.Sprintf("collective of %s (%s) differs from baseline %s (%s)",
+			run.ID, run.Collective, base.ID, base.Collective))
+	}
+	if base.Hosts > 0 && run.Hosts > 0 && base.Hosts != run.Hosts {
+		warnings = append(warnings, fmt.Sprintf("%s ran on %d hosts but baseline %s ran on %d",
+			run.ID, run.Hosts, base.ID, base.Hosts))
+	}
+	if len(run.Topology) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%s has topology issues: %s", run.ID, strings.Join(run.Topology, "; ")))
+	}
+	if base.WorldSize > 0 && run.WorldSize > 0 && base.WorldSize != run.WorldSize {
+		warnings = append(warnings, fmt.Sprintf("%s ran with %d ranks but baseline %s ran with %d",
+			run.ID, run.WorldSize, base.ID, base.WorldSize))
+	}
+	if base.NCCLVersion != "" && run.NCCLVersion != "" && base.NCCLVersion != run.NCCLVersion {
+		warnings = append(warnings, fmt.Sprintf("NCCL version of %s (%s) differs from baseline %s (%s)",
+			run.ID, run.NCCLVersion, base.ID, base.NCCLVersion))
+	}
+	if base.CUDAVersion != "" && run.CUDAVersion != "" && base.CUDAVersion != run.CUDAVersion {
+		warnings = append(warnings, fmt.Sprintf("CUDA version of %s (%s) differs from baseline %s (%s)",
+			run.ID, run.CUDAVersion, base.ID, base.CUDAVersion))
+	}
+	return warnings
+}
+
+// compareSeries 按测试大小对齐两组数据点并计算差异
+func compareSeries(base, run []ChartDataPoint, byType bool, tolerance float64) RunComparison {
+	key := func(p ChartDataPoint) string {
+		if byType {
+			return p.Type + "/" + strconv.Itoa(p.Size)
+		}
+		return strconv.Itoa(p.Size)
+	}
+
+	runPoints := make(map[string]ChartDataPoint, len(run))
+	for _, p := range run {
+		if _, ok := runPoints[key(p)]; !ok {
+			runPoints[key(p)] = p
+		}
+	}
+
+	cmp := RunComparison{
+		Sizes:             []SizeComparison{},
+		MissingInRun:      []int{},
+		MissingInBaseline: []int{},
+	}
+	matched := make(map[string]bool)
+	for _, b := range base {
+		k := key(b)
+		if matched[k] {
+			continue
+		}
+		p, ok := runPoints[k]
+		if !ok {
+			cmp.MissingInRun = append(cmp.MissingInRun, b.Size)
+			continue
+		}
+		matched[k] = true
+
+		sc := SizeComparison{
+			Size:   b.Size,
+			Type:   b.Type,
+			Deltas: make(map[string]MetricDelta, len(compareMetrics)),
+		}
+		for _, metric := range compareMetrics {
+			delta := newMetricDelta(metric.Value(b), metric.Value(p))
+			sc.Deltas[metric.Name] = delta
+			if delta.Pct != nil && math.Abs(*delta.Pct) > tolerance {
+				sc.Exceeded = append(sc.Exceeded, metric.Name)
+			}
+		}
+		if len(sc.Exceeded) > 0 {
+			cmp.ExceededSizes++
+		}
+		cmp.Sizes = append(cmp.Sizes, sc)
+	}
+
+	for _, p := range run {
+		if _, ok := matched[key(p)]; !ok {
+			matched[key(p)] = true
+			cmp.MissingInBaseline = append(cmp.MissingInBaseline, p.Size)
+		}
+	}
+	return cmp
+}
+
+// newMetricDelta 计算指标差异，基准值为 0 时不计算百分比
+func newMetricDelta(base, value float64) MetricDelta {
+	delta := MetricDelta{
+		Baseline: base,
+		Value:    value,
+		Abs:      round(value-base, 4),
+	}
+	if base != 0 {
+		pct := round((value-base)/base*100, 2)
+		delta.Pct = &pct
+	}
+	return delta
+}
+
+// round 保留 n 位小数
+func round(v float64, n int) float64 {
+	scale := math.Pow(10, float64(n))
+	return math.Round(v*scale) / scale
+}
+
+// formatBytes 将字节数格式化为 K/M/G 形式，与 nccl-tests 参数一致
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return strconv.Itoa(n>>30) + "G"
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return strconv.Itoa(n>>20) + "M"
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return strconv.Itoa(n>>10) + "K"
+	}
+	return strconv.Itoa(n)
+}
+
+// CompareHistory 对比两个或多个运行：GET /compare?runs=a,b,c&tolerance=5
+// 第一个运行作为基准，tolerance 为百分比，默认 5
+func CompareHistory(c *gin.Context) {
+	var ids []string
+	for _, id := range strings.Split(c.Query("runs"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < 2 || len(ids) > MaxCompareRuns {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("runs must list between 2 and %d comma-separated run IDs", MaxCompareRuns),
+		})
+		return
+	}
+
+	tolerance := DefaultCompareTolerance
+	if s := c.Query("tolerance"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "tolerance must be a non-negative percentage",
+			})
+			return
+		}
+		tolerance = v
+	}
+
+	sources := make([]compareSource, 0, len(ids))
+	for _, raw := range ids {
+		id, ok := historyID(raw)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid run ID: " + raw,
+			})
+			return
+		}
+
+		src, err := loadCompareSource(id)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, errCompareRunNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, errCompareRunNotFinished):
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{
+				"error": fmt.Sprintf("%s: %v", id, err),
+			})
+			return
+		}
+		sources = append(sources, src)
+	}
+
+	c.JSON(http.StatusOK, CompareRuns(sources, tolerance))
+}
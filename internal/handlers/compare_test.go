@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func comparePoint(size int, datatype string, busbw float64) ChartDataPoint {
+	return ChartDataPoint{
+		Size: size, Count: size / 4, Type: datatype,
+		OutTime: 100, OutAlgbw: busbw / 2, OutBusbw: busbw,
+		InTime: 100, InAlgbw: busbw / 2, InBusbw: busbw,
+	}
+}
+
+func compareResults(id string, points ...ChartDataPoint) compareSource {
+	return compareSource{results: RunResults{ID: id, DataPoints: points, Summary: summarizeResults(points)}}
+}
+
+func TestCompareRuns(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		run           compareSource
+		exceededSizes int
+		missingInRun  int
+		missingInBase int
+		warning       string // 期望出现的警告，空表示没有警告
+	}{
+		{
+			desc:          "容差范围内",
+			run:           compareResults("b", comparePoint(1024, "float", 10.2), comparePoint(2048, "float", 19.5)),
+			exceededSizes: 0,
+		},
+		{
+			desc:          "超过容差",
+			run:           compareResults("b", comparePoint(1024, "float", 10), comparePoint(2048, "float", 15)),
+			exceededSizes: 1,
+		},
+		{
+			desc:          "大小范围不同",
+			run:           compareResults("b", comparePoint(2048, "float", 20), comparePoint(4096, "float", 40)),
+			missingInRun:  1,
+			missingInBase: 1,
+			warning:       "size range of b (2K-4K) differs from baseline a (1K-2K)",
+		},
+		{
+			desc:    "数据类型不同时按大小对齐",
+			run:     compareResults("b", comparePoint(1024, "half", 10), comparePoint(2048, "half", 20)),
+			warning: "datatype of b (half) differs from baseline a (float)",
+		},
+	}
+
+	base := compareResults("a", comparePoint(1024, "float", 10), comparePoint(2048, "float", 20))
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			resp := CompareRuns([]compareSource{base, tc.run}, DefaultCompareTolerance)
+			if resp.Baseline != "a" || len(resp.Comparisons) != 1 {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+
+			cmp := resp.Comparisons[0]
+			if cmp.ExceededSizes != tc.exceededSizes || len(cmp.MissingInRun) != tc.missingInRun || len(cmp.MissingInBaseline) != tc.missingInBase {
+				t.Errorf("exceeded=%d missing_in_run=%v missing_in_baseline=%v",
+					cmp.ExceededSizes, cmp.MissingInRun, cmp.MissingInBaseline)
+			}
+
+			warnings := strings.Join(resp.Warnings, "\n")
+			if tc.warning == "" && warnings != "" {
+				t.Errorf("unexpected warnings: %s", warnings)
+			}
+			if tc.warning != "" && !strings.Contains(warnings, tc.warning) {
+				t.Errorf("warnings %q should contain %q", warnings, tc.warning)
+			}
+		})
+	}
+}
+
+func TestNewMetricDelta(t *testing.T) {
+	d := newMetricDelta(20, 15)
+	if d.Abs != -5 || d.Pct == nil || *d.Pct != -25 {
+		t.Errorf("unexpected delta: %+v", d)
+	}
+	if d := newMetricDelta(0, 1); d.Pct != nil {
+		t.Errorf("pct should be nil when baseline is 0, got %v", *d.Pct)
+	}
+}
+
+func TestCompareHistoryIDs(t *testing.T) {
+	oldDir := HistoryDir
+	HistoryDir = t.TempDir()
+	defer func() { HistoryDir = oldDir }()
+
+	params := validParams()
+	for _, id := range []string{"20251120_143022_a1b2c3", "20251120_143022_d4e5f6"} {
+		info := RunInfo{ID: id, Status: RunStatusSuccess, Command: "nccl_test", Params: params, Hosts: []string{"node01"}}
+		output := info.Command + "\n\n" + fakeNCCLOutput(params, info.Hosts, 1)
+		if err := saveHistoryFile(newHistoryMeta(info, nil), output); err != nil {
+			t.Fatalf("saveHistoryFile: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		desc   string
+		runs   string
+		status int
+	}{
+		{"运行 ID", "20251120_143022_a1b2c3,20251120_143022_d4e5f6", http.StatusOK},
+		{"历史文件名", "20251120_143022_a1b2c3.txt,20251120_143022_d4e5f6.txt", http.StatusOK},
+		{"不存在的运行", "20251120_143022_a1b2c3,20251120_143022_000000.txt", http.StatusNotFound},
+		{"非法 ID", "20251120_143022_a1b2c3,../secret", http.StatusBadRequest},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/compare?runs="+tc.runs, nil)
+
+			CompareHistory(c)
+			if w.Code != tc.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tc.status, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	// DefaultPort 默认监听端口
+	DefaultPort = "8098"
+	// ConfigEnvPrefix 覆盖配置项的环境变量前缀
+	ConfigEnvPrefix = "NCCL_WEB_"
+)
+
+// Config 服务配置，加载顺序：内置默认值 < 配置文件 < NCCL_WEB_* 环境变量
+type Config struct {
+	Port           string         `yaml:"port"`            // 监听端口
+	DataDir        string         `yaml:"data_dir"`        // 数据存储目录
+	HistoryDir     string         `yaml:"history_dir"`     // 历史记录目录，为空时使用 <data_dir>/history
+	MaxConcurrency int            `yaml:"max_concurrency"` // 预检查的 SSH 并发数
+	Binaries       BinaryConfig   `yaml:"binaries"`        // 可执行文件路径
+	Defaults       NCCLTestParams `yaml:"defaults"`        // 前端使用的默认测试参数，只需填写要修改的字段
+	EnvAllowlist   []EnvRule      `yaml:"env_allowlist"`   // 替换内置的环境变量白名单，DataDir 下的 env_allowlist.json 优先
+}
+
+// BinaryConfig 可执行文件路径
+type BinaryConfig struct {
+	MPIRun       string `yaml:"mpirun"`         // Open MPI mpirun
+	Srun         string `yaml:"srun"`           // Slurm srun
+	Salloc       string `yaml:"salloc"`         // Slurm salloc
+	NCCLTestsDir string `yaml:"nccl_tests_dir"` // nccl-tests 安装目录
+	NCCLTest     string `yaml:"nccl_test"`      // nccl_test 启动脚本，为空时使用 <nccl_tests_dir>/nccl_test
+}
+
+// portPattern 监听端口
+var portPattern = regexp.MustCompile(`^\d{1,5}$`)
+
+// DefaultConfig 返回内置默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		Port:           DefaultPort,
+		DataDir:        DataDir,
+		MaxConcurrency: MaxConcurrency,
+		Binaries: BinaryConfig{
+			MPIRun:       MPIRunPath,
+			Srun:         SrunPath,
+			Salloc:       SallocPath,
+			NCCLTestsDir: NCCLTestsDir,
+		},
+		Defaults:     DefaultParams,
+		EnvAllowlist: defaultEnvAllowlist,
+	}
+}
+
+// LoadConfig 加载配置：file 为空时只使用内置默认值和环境变量，加载后进行校验
+func LoadConfig(file string) (*Config, error) {
+	cfg := DefaultConfig()
+
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+		if err := yaml.UnmarshalWithOptions(data, cfg, yaml.DisallowUnknownField()); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %v", file, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	// YAML 中的数字会解码为整数类型，转换为与 JSON 请求一致的表示
+	defaults, err := normalizeParams(cfg.Defaults)
+	if err != nil {
+		return nil, fmt.Errorf("invalid defaults: %v", err)
+	}
+	cfg.Defaults = defaults
+
+	if cfg.HistoryDir == "" {
+		cfg.HistoryDir = filepath.Join(cfg.DataDir, "history")
+	}
+	if cfg.Binaries.NCCLTest == "" {
+		cfg.Binaries.NCCLTest = filepath.Join(cfg.Binaries.NCCLTestsDir, "nccl_test")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv 使用 NCCL_WEB_* 环境变量覆盖配置项
+func (cfg *Config) applyEnv() error {
+	stringVars := map[string]*string{
+		"PORT":                      &cfg.Port,
+		"DATA_DIR":                  &cfg.DataDir,
+		"HISTORY_DIR":               &cfg.HistoryDir,
+		"MPIRUN":                    &cfg.Binaries.MPIRun,
+		"SRUN":                      &cfg.Binaries.Srun,
+		"SALLOC":                    &cfg.Binaries.Salloc,
+		"NCCL_TESTS_DIR":            &cfg.Binaries.NCCLTestsDir,
+		"NCCL_TEST":                 &cfg.Binaries.NCCLTest,
+		"DEFAULT_LAUNCHER":          &cfg.Defaults.Launcher,
+		"DEFAULT_MAP_BY":            &cfg.Defaults.MapBy,
+		"DEFAULT_OOB_TCP_INTERFACE": &cfg.Defaults.OOBTCPInterface,
+		"DEFAULT_BTL_TCP_INTERFACE": &cfg.Defaults.BTLTCPInterface,
+		"DEFAULT_IPLIST_FILE":       &cfg.Defaults.IPListFile,
+		"DEFAULT_NCCL_DEBUG_LEVEL":  &cfg.Defaults.NCCLDebugLevel,
+		"DEFAULT_COLLECTIVE":        &cfg.Defaults.Collective,
+		"DEFAULT_DATATYPE":          &cfg.Defaults.Datatype,
+		"DEFAULT_OP":                &cfg.Defaults.Op,
+	}
+	for name, field := range stringVars {
+		if v, ok := os.LookupEnv(ConfigEnvPrefix + name); ok {
+			*field = v
+		}
+	}
+
+	intVars := map[string]*int{
+		"MAX_CONCURRENCY":                    &cfg.MaxConcurrency,
+		"DEFAULT_NCCL_IB_GID_INDEX":          &cfg.Defaults.NCCLIBGIDIndex,
+		"DEFAULT_NCCL_MIN_CHANNELS":          &cfg.Defaults.NCCLMinChannels,
+		"DEFAULT_NCCL_IB_QPS_PER_CONNECTION": &cfg.Defaults.NCCLIBQPSPerConnection,
+		"DEFAULT_TIMEOUT":                    &cfg.Defaults.Timeout,
+	}
+	for name, field := range intVars {
+		v, ok := os.LookupEnv(ConfigEnvPrefix + name)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("invalid %s%s: %q is not an integer", ConfigEnvPrefix, name, v)
+		}
+		*field = n
+	}
+	return nil
+}
+
+// Validate 校验配置，返回所有不合法的配置项
+func (cfg *Config) Validate() error {
+	var errs []string
+
+	if n, err := strconv.Atoi(cfg.Port); !portPattern.MatchString(cfg.Port) || err != nil || n < 1 || n > 65535 {
+		errs = append(errs, fmt.Sprintf("port: %q must be between 1 and 65535", cfg.Port))
+	}
+	if cfg.DataDir == "" {
+		errs = append(errs, "data_dir: must not be empty")
+	}
+	if cfg.MaxConcurrency < 1 || cfg.MaxConcurrency > 1024 {
+		errs = append(errs, "max_concurrency: must be between 1 and 1024")
+	}
+
+	binaries := map[string]string{
+		"binaries.mpirun":         cfg.Binaries.MPIRun,
+		"binaries.srun":           cfg.Binaries.Srun,
+		"binaries.salloc":         cfg.Binaries.Salloc,
+		"binaries.nccl_tests_dir": cfg.Binaries.NCCLTestsDir,
+		"binaries.nccl_test":      cfg.Binaries.NCCLTest,
+	}
+	for name, value := range binaries {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, name+": must not be empty")
+		}
+	}
+
+	for i, rule := range cfg.EnvAllowlist {
+		if _, err := path.Match(rule.Name, ""); err != nil || rule.Name == "" {
+			errs = append(errs, fmt.Sprintf("env_allowlist[%d].name: invalid pattern %q", i, rule.Name))
+		}
+		if _, err := regexp.Compile(rule.Value); err != nil {
+			errs = append(errs, fmt.Sprintf("env_allowlist[%d].value: %v", i, err))
+		}
+	}
+
+	// 默认参数需要能直接提交，iplist_file 由用户选择，校验时使用占位值
+	defaults := cfg.Defaults
+	if defaults.IPListFile == "" {
+		defaults.IPListFile = DefaultIPListFile
+	}
+	env := defaults.Env
+	defaults.Env = nil
+	fields := make(map[string]string)
+	var invalid *ValidationError
+	if errors.As(defaults.Validate(), &invalid) {
+		for field, msg := range invalid.Fields {
+			fields[field] = msg
+		}
+	}
+	for field, msg := range checkEnvRules(env, cfg.EnvAllowlist) {
+		fields[field] = msg
+	}
+	for field, msg := range fields {
+		errs = append(errs, "defaults."+field+": "+msg)
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("invalid config:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// Apply 将配置应用到服务
+func (cfg *Config) Apply() {
+	DataDir = cfg.DataDir
+	HistoryDir = cfg.HistoryDir
+	MaxConcurrency = cfg.MaxConcurrency
+
+	MPIRunPath = cfg.Binaries.MPIRun
+	SrunPath = cfg.Binaries.Srun
+	SallocPath = cfg.Binaries.Salloc
+	NCCLTestsDir = cfg.Binaries.NCCLTestsDir
+	NCCLTestPath = cfg.Binaries.NCCLTest
+
+	DefaultParams = cfg.Defaults
+	if cfg.Defaults.Launcher != "" {
+		DefaultLauncher = cfg.Defaults.Launcher
+	}
+	defaultEnvAllowlist = cfg.EnvAllowlist
+}
+
+// normalizeParams 通过 JSON 往返转换参数，使大小等字段的类型与 HTTP 请求解码的结果一致
+func normalizeParams(params NCCLTestParams) (NCCLTestParams, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return params, err
+	}
+
+	var normalized NCCLTestParams
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return params, err
+	}
+	return normalized, nil
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		content string            // 配置文件内容，空表示不使用配置文件
+		env     map[string]string // 环境变量
+		errLike string            // 期望错误包含的文本，空表示加载成功
+		check   func(t *testing.T, cfg *Config)
+	}{
+		{
+			desc: "内置默认值",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Port != DefaultPort || cfg.HistoryDir != filepath.Join(cfg.DataDir, "history") {
+					t.Errorf("unexpected defaults: port=%s history_dir=%s", cfg.Port, cfg.HistoryDir)
+				}
+				if cfg.Binaries.NCCLTest != filepath.Join(cfg.Binaries.NCCLTestsDir, "nccl_test") {
+					t.Errorf("unexpected nccl_test: %s", cfg.Binaries.NCCLTest)
+				}
+			},
+		},
+		{
+			desc: "配置文件只覆盖填写的字段",
+			content: `
+port: "9000"
+data_dir: /srv/nccl
+binaries:
+  nccl_tests_dir: /opt/nccl-tests
+defaults:
+  oob_tcp_interface: eth0
+  test_size_begin: 8
+  test_size_end: 1G
+`,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Port != "9000" || cfg.HistoryDir != "/srv/nccl/history" {
+					t.Errorf("unexpected port/history_dir: %s %s", cfg.Port, cfg.HistoryDir)
+				}
+				if cfg.Binaries.NCCLTest != "/opt/nccl-tests/nccl_test" || cfg.Binaries.MPIRun != MPIRunPath {
+					t.Errorf("unexpected binaries: %+v", cfg.Binaries)
+				}
+				if cfg.Defaults.OOBTCPInterface != "eth0" || cfg.Defaults.BTLTCPInterface != "bond0" {
+					t.Errorf("unexpected interfaces: %s %s", cfg.Defaults.OOBTCPInterface, cfg.Defaults.BTLTCPInterface)
+				}
+				if _, ok := cfg.Defaults.TestSizeBegin.(float64); !ok {
+					t.Errorf("test_size_begin should be normalized to float64, got %T", cfg.Defaults.TestSizeBegin)
+				}
+			},
+		},
+		{
+			desc:    "环境变量优先于配置文件",
+			content: "port: \"9000\"\n",
+			env:     map[string]string{"NCCL_WEB_PORT": "9100", "NCCL_WEB_DEFAULT_NCCL_IB_GID_INDEX": "5"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Port != "9100" || cfg.Defaults.NCCLIBGIDIndex != 5 {
+					t.Errorf("env override not applied: port=%s gid=%d", cfg.Port, cfg.Defaults.NCCLIBGIDIndex)
+				}
+			},
+		},
+		{
+			desc:    "未知配置项",
+			content: "prot: 9000\n",
+			errLike: "unknown field",
+		},
+		{
+			desc:    "非法端口",
+			content: "port: \"70000\"\n",
+			errLike: "port:",
+		},
+		{
+			desc:    "非法的默认参数",
+			content: "defaults:\n  map_by: \"ppr:8:node; rm -rf /\"\n  launcher: pbs\n",
+			errLike: "defaults.launcher",
+		},
+		{
+			desc:    "默认环境变量不在白名单中",
+			content: "defaults:\n  env:\n    LD_PRELOAD: /tmp/x.so\n",
+			errLike: "defaults.env.LD_PRELOAD",
+		},
+		{
+			desc:    "非法的白名单正则",
+			content: "env_allowlist:\n  - name: NCCL_*\n    value: \"([\"\n",
+			errLike: "env_allowlist[0].value",
+		},
+		{
+			desc:    "环境变量不是整数",
+			env:     map[string]string{"NCCL_WEB_MAX_CONCURRENCY": "many"},
+			errLike: "NCCL_WEB_MAX_CONCURRENCY",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			file := ""
+			if tc.content != "" {
+				file = filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(file, []byte(tc.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg, err := LoadConfig(file)
+			if tc.errLike != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.errLike) {
+					t.Fatalf("expected error containing %q, got %v", tc.errLike, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tc.check(t, cfg)
+		})
+	}
+}
@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CriteriaDir 命名验收标准存储目录，位于 DataDir 下，每个标准一个 <name>.json 文件
+	CriteriaDir = "criteria"
+)
+
+// 验收结论
+const (
+	AcceptancePass = "PASS"
+	AcceptanceFail = "FAIL"
+)
+
+// thresholdMetrics 阈值支持的指标，值为该指标对应的数据点字段
+// busbw、algbw、time 同时检查 out-of-place 和 in-place，wrong 为两者 #wrong 的合计
+var thresholdMetrics = map[string][]string{
+	"busbw":     {"out_busbw", "in_busbw"},
+	"algbw":     {"out_algbw", "in_algbw"},
+	"time":      {"out_time", "in_time"},
+	"out_busbw": {"out_busbw"},
+	"in_busbw":  {"in_busbw"},
+	"out_algbw": {"out_algbw"},
+	"in_algbw":  {"in_algbw"},
+	"out_time":  {"out_time"},
+	"in_time":   {"in_time"},
+	"wrong":     {"wrong"},
+}
+
+// runMetrics 针对整个运行而不是单个大小的指标
+var runMetrics = map[string]bool{
+	"avg_busbw":     true,
+	"peak_busbw":    true,
+	"out_of_bounds": true,
+}
+
+// thresholdOps 阈值支持的比较运算符
+var thresholdOps = map[string]func(a, b float64) bool{
+	">=": func(a, b float64) bool { return a >= b },
+	">":  func(a, b float64) bool { return a > b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"<":  func(a, b float64) bool { return a < b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// Threshold 验收阈值：在指定大小（或大小范围）上，指标与值比较必须成立
+// 例如 {"size": "1G", "metric": "busbw", "op": ">=", "value": 360}、{"metric": "wrong", "op": "==", "value": 0}
+type Threshold struct {
+	Name    string      `json:"name,omitempty"`     // 说明，为空时自动生成
+	Size    interface{} `json:"size,omitempty"`     // 单个大小，支持 int 或 string (如 "1G")
+	MinSize interface{} `json:"min_size,omitempty"` // 大小范围下限（含），与 size 互斥
+	MaxSize interface{} `json:"max_size,omitempty"` // 大小范围上限（含），与 size 互斥
+	Metric  string      `json:"metric"`             // busbw, algbw, time, out_busbw, in_busbw, wrong 等，或 avg_busbw, peak_busbw, out_of_bounds
+	Op      string      `json:"op"`                 // >=, >, <=, <, ==, !=
+	Value   float64     `json:"value"`
+}
+
+// Criteria 命名验收标准
+type Criteria struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Thresholds  []Threshold `json:"thresholds" binding:"required"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// AcceptanceResult 验收结果
+type AcceptanceResult struct {
+	Result   string           `json:"result"` // PASS 或 FAIL
+	Criteria string           `json:"criteria,omitempty"`
+	Checks   []ThresholdCheck `json:"checks"`
+	Reasons  []string         `json:"reasons"` // 失败原因
+}
+
+// ThresholdCheck 单个阈值的检查结果
+type ThresholdCheck struct {
+	Threshold Threshold `json:"threshold"`
+	Name      string    `json:"name"`
+	Passed    bool      `json:"passed"`
+	Message   string    `json:"message"`
+}
+
+// String 返回阈值的文本描述，如 busbw >= 360 at 1G
+func (t Threshold) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+
+	desc := fmt.Sprintf("%s %s %g", t.Metric, t.Op, t.Value)
+	switch {
+	case sizeArg(t.Size) != "":
+		desc += " at " + sizeArg(t.Size)
+	case sizeArg(t.MinSize) != "" || sizeArg(t.MaxSize) != "":
+		min, max := sizeArg(t.MinSize), sizeArg(t.MaxSize)
+		if min == "" {
+			min = "0"
+		}
+		if max == "" {
+			max = "max"
+		}
+		desc += fmt.Sprintf(" for %s-%s", min, max)
+	}
+	return desc
+}
+
+// checkThresholds 校验阈值定义，返回字段名（thresholds[i].field）到错误信息的映射
+func checkThresholds(thresholds []Threshold) map[string]string {
+	fields := make(map[string]string)
+
+	for i, t := range thresholds {
+		prefix := fmt.Sprintf("thresholds[%d].", i)
+
+		if _, ok := thresholdMetrics[t.Metric]; !ok && !runMetrics[t.Metric] {
+			fields[prefix+"metric"] = "must be one of busbw, algbw, time, out_busbw, in_busbw, out_algbw, in_algbw, out_time, in_time, wrong, avg_busbw, peak_busbw, out_of_bounds"
+		}
+		if _, ok := thresholdOps[t.Op]; !ok {
+			fields[prefix+"op"] = "must be one of >=, >, <=, <, ==, !="
+		}
+
+		size, msg := validateSize(t.Size)
+		if msg != "" {
+			fields[prefix+"size"] = msg
+		}
+		min, msg := validateSize(t.MinSize)
+		if msg != "" {
+			fields[prefix+"min_size"] = msg
+		}
+		max, msg := validateSize(t.MaxSize)
+		if msg != "" {
+			fields[prefix+"max_size"] = msg
+		}
+		if size >= 0 && (min >= 0 || max >= 0) {
+			fields[prefix+"size"] = "cannot be used together with min_size/max_size"
+		}
+		if min >= 0 && max >= 0 && min > max {
+			fields[prefix+"max_size"] = "must not be smaller than min_size"
+		}
+		if runMetrics[t.Metric] && (size >= 0 || min >= 0 || max >= 0) {
+			fields[prefix+"size"] = t.Metric + " applies to the whole run and cannot have a size"
+		}
+	}
+	return fields
+}
+
+// criteriaPath 返回命名验收标准的文件路径
+func criteriaPath(name string) string {
+	return filepath.Join(DataDir, CriteriaDir, name+".json")
+}
+
+// loadCriteria 读取命名验收标准
+func loadCriteria(name string) (*Criteria, error) {
+	if !filenamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid criteria name: %s", name)
+	}
+
+	data, err := os.ReadFile(criteriaPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("criteria %s not found", name)
+		}
+		return nil, fmt.Errorf("failed to read criteria: %v", err)
+	}
+
+	var criteria Criteria
+	if err := json.Unmarshal(data, &criteria); err != nil {
+		return nil, fmt.Errorf("failed to parse criteria: %v", err)
+	}
+	criteria.Name = name
+	return &criteria, nil
+}
+
+// resolveThresholds 合并命名验收标准和请求中的阈值，提交时展开，之后修改标准不影响已提交的运行
+func resolveThresholds(params NCCLTestParams) ([]Threshold, error) {
+	var thresholds []Threshold
+	if params.Criteria != "" {
+		criteria, err := loadCriteria(params.Criteria)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, criteria.Thresholds...)
+	}
+	return append(thresholds, params.Thresholds...), nil
+}
+
+// evaluateAcceptance 根据运行状态和解析结果检查所有阈值，运行未成功结束时直接判定为 FAIL
+func evaluateAcceptance(criteria string, thresholds []Threshold, status string, results RunResults) *AcceptanceResult {
+	acceptance := &AcceptanceResult{
+		Result:   AcceptancePass,
+		Criteria: criteria,
+		Checks:   []ThresholdCheck{},
+		Reasons:  []string{},
+	}
+
+	if status != RunStatusSuccess {
+		acceptance.Reasons = append(acceptance.Reasons, fmt.Sprintf("run finished with status %s", status))
+	}
+
+	for _, t := range thresholds {
+		check := checkThreshold(t, results)
+		acceptance.Checks = append(acceptance.Checks, check)
+		if !check.Passed {
+			acceptance.Reasons = append(acceptance.Reasons, check.Name+": "+check.Message)
+		}
+	}
+
+	if len(acceptance.Reasons) > 0 {
+		acceptance.Result = AcceptanceFail
+	}
+	return acceptance
+}
+
+// checkThreshold 检查单个阈值，选中的每个数据点都必须满足条件；没有选中任何数据点时判定为失败
+func checkThreshold(t Threshold, results RunResults) ThresholdCheck {
+	check := ThresholdCheck{Threshold: t, Name: t.String()}
+	compare := thresholdOps[t.Op]
+
+	switch t.Metric {
+	case "avg_busbw":
+		if results.Summary.AvgBusbw == nil {
+			check.Message = "average bus bandwidth was not reported"
+			return check
+		}
+		check.Passed = compare(*results.Summary.AvgBusbw, t.Value)
+		check.Message = fmt.Sprintf("avg_busbw = %g", *results.Summary.AvgBusbw)
+		return check
+	case "peak_busbw":
+		check.Passed = results.Summary.Points > 0 && compare(results.Summary.PeakBusbw, t.Value)
+		check.Message = fmt.Sprintf("peak_busbw = %g", results.Summary.PeakBusbw)
+		return check
+	case "out_of_bounds":
+		if results.Summary.OutOfBounds == nil {
+			check.Message = "out of bounds values were not reported"
+			return check
+		}
+		check.Passed = compare(float64(*results.Summary.OutOfBounds), t.Value)
+		check.Message = fmt.Sprintf("out_of_bounds = %d", *results.Summary.OutOfBounds)
+		return check
+	}
+
+	size, _ := validateSize(t.Size)
+	min, _ := validateSize(t.MinSize)
+	max, _ := validateSize(t.MaxSize)
+
+	var matched, failed int
+	var failures []string
+	for _, p := range results.DataPoints {
+		s := int64(p.Size)
+		if (size >= 0 && s != size) || (min >= 0 && s < min) || (max >= 0 && s > max) {
+			continue
+		}
+		matched++
+
+		ok := true
+		for _, name := range thresholdMetrics[t.Metric] {
+			value := pointMetric(p, name)
+			if !compare(value, t.Value) {
+				ok = false
+				failures = append(failures, fmt.Sprintf("%s %s = %g", formatBytes(p.Size), name, value))
+			}
+		}
+		if !ok {
+			failed++
+		}
+	}
+
+	switch {
+	case matched == 0:
+		check.Message = "no data points in the selected size range"
+	case failed > 0:
+		check.Message = fmt.Sprintf("failed at %d of %d sizes: %s", failed, matched, strings.Join(failures, ", "))
+	default:
+		check.Passed = true
+		check.Message = fmt.Sprintf("passed at %d sizes", matched)
+	}
+	return check
+}
+
+// pointMetric 返回数据点的指定指标
+func pointMetric(p ChartDataPoint, name string) float64 {
+	if name == "wrong" {
+		return float64(p.OutWrong + p.InWrong)
+	}
+	for _, metric := range compareMetrics {
+		if metric.Name == name {
+			return metric.Value(p)
+		}
+	}
+	return 0
+}
+
+// GetCriteriaList 获取所有命名验收标准
+func GetCriteriaList(c *gin.Context) {
+	entries, err := os.ReadDir(filepath.Join(DataDir, CriteriaDir))
+	if err != nil && !os.IsNotExist(err) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to read criteria directory",
+		})
+		return
+	}
+
+	list := []Criteria{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		criteria, err := loadCriteria(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			fmt.Printf("Failed to load criteria %s: %v\n", entry.Name(), err)
+			continue
+		}
+		list = append(list, *criteria)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":    len(list),
+		"criteria": list,
+	})
+}
+
+// GetCriteria 获取指定的命名验收标准
+func GetCriteria(c *gin.Context) {
+	criteria, err := loadCriteria(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, criteria)
+}
+
+// SaveCriteria 创建或更新命名验收标准
+func SaveCriteria(c *gin.Context) {
+	name := c.Param("name")
+	if !filenamePattern.MatchString(name) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid criteria name",
+		})
+		return
+	}
+
+	var criteria Criteria
+	if err := c.ShouldBindJSON(&criteria); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if fields := checkThresholds(criteria.Thresholds); len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Invalid parameters",
+			"fields": fields,
+		})
+		return
+	}
+	criteria.Name = name
+	criteria.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(criteria, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Join(DataDir, CriteriaDir), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(criteriaPath(name), data, 0644)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to save criteria: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, criteria)
+}
+
+// DeleteCriteria 删除命名验收标准，已提交的运行不受影响
+func DeleteCriteria(c *gin.Context) {
+	name := c.Param("name")
+	if !filenamePattern.MatchString(name) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid criteria name",
+		})
+		return
+	}
+
+	if err := os.Remove(criteriaPath(name)); err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Criteria not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete criteria",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Criteria deleted successfully",
+	})
+}
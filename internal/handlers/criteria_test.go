@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckThresholds(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		threshold Threshold
+		field     string // 期望出错的字段，空表示校验通过
+	}{
+		{
+			desc:      "单个大小",
+			threshold: Threshold{Size: "1G", Metric: "busbw", Op: ">=", Value: 360},
+		},
+		{
+			desc:      "大小范围",
+			threshold: Threshold{MinSize: float64(1 << 20), MaxSize: "1G", Metric: "out_time", Op: "<", Value: 5000},
+		},
+		{
+			desc:      "整个运行的指标",
+			threshold: Threshold{Metric: "avg_busbw", Op: ">", Value: 100},
+		},
+		{
+			desc:      "未知指标",
+			threshold: Threshold{Metric: "bandwidth", Op: ">=", Value: 1},
+			field:     "thresholds[0].metric",
+		},
+		{
+			desc:      "未知运算符",
+			threshold: Threshold{Metric: "busbw", Op: "=>", Value: 1},
+			field:     "thresholds[0].op",
+		},
+		{
+			desc:      "size 与范围同时设置",
+			threshold: Threshold{Size: "1G", MinSize: "1M", Metric: "busbw", Op: ">=", Value: 1},
+			field:     "thresholds[0].size",
+		},
+		{
+			desc:      "范围上限小于下限",
+			threshold: Threshold{MinSize: "1G", MaxSize: "1M", Metric: "busbw", Op: ">=", Value: 1},
+			field:     "thresholds[0].max_size",
+		},
+		{
+			desc:      "整个运行的指标不能指定大小",
+			threshold: Threshold{Size: "1G", Metric: "peak_busbw", Op: ">=", Value: 1},
+			field:     "thresholds[0].size",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fields := checkThresholds([]Threshold{tc.threshold})
+			if tc.field == "" && len(fields) > 0 {
+				t.Errorf("unexpected errors: %v", fields)
+			}
+			if tc.field != "" && fields[tc.field] == "" {
+				t.Errorf("expected error on %s, got %v", tc.field, fields)
+			}
+		})
+	}
+}
+
+func TestEvaluateAcceptance(t *testing.T) {
+	avg := 15.0
+	points := []ChartDataPoint{
+		comparePoint(1024, "float", 10),
+		comparePoint(2048, "float", 20),
+		comparePoint(4096, "float", 40),
+	}
+	points[1].InWrong = 3
+	oob := 0
+	results := RunResults{ID: "a", DataPoints: points, Summary: summarizeResults(points)}
+	results.Summary.AvgBusbw = &avg
+	results.Summary.OutOfBounds = &oob
+
+	testCases := []struct {
+		desc       string
+		status     string
+		thresholds []Threshold
+		result     string
+		reasons    int
+	}{
+		{
+			desc:   "全部通过",
+			status: RunStatusSuccess,
+			thresholds: []Threshold{
+				{Size: float64(4096), Metric: "busbw", Op: ">=", Value: 40},
+				{MinSize: "2K", Metric: "out_busbw", Op: ">", Value: 15},
+				{Metric: "avg_busbw", Op: ">=", Value: 15},
+			},
+			result: AcceptancePass,
+		},
+		{
+			desc:   "范围内有大小不满足",
+			status: RunStatusSuccess,
+			thresholds: []Threshold{
+				{MaxSize: "2K", Metric: "busbw", Op: ">=", Value: 15},
+			},
+			result:  AcceptanceFail,
+			reasons: 1,
+		},
+		{
+			desc:   "没有匹配的大小",
+			status: RunStatusSuccess,
+			thresholds: []Threshold{
+				{Size: "1G", Metric: "busbw", Op: ">=", Value: 1},
+			},
+			result:  AcceptanceFail,
+			reasons: 1,
+		},
+		{
+			desc:   "指定大小的带宽和 #wrong",
+			status: RunStatusSuccess,
+			thresholds: []Threshold{
+				{Size: "4K", Metric: "busbw", Op: ">=", Value: 40},
+				{Size: "4K", Metric: "wrong", Op: "==", Value: 0},
+				{Metric: "out_of_bounds", Op: "==", Value: 0},
+			},
+			result: AcceptancePass,
+		},
+		{
+			desc:   "有 #wrong 的大小",
+			status: RunStatusSuccess,
+			thresholds: []Threshold{
+				{Metric: "wrong", Op: "==", Value: 0},
+			},
+			result:  AcceptanceFail,
+			reasons: 1,
+		},
+		{
+			desc:   "运行失败时判定为 FAIL",
+			status: RunStatusTimeout,
+			thresholds: []Threshold{
+				{Metric: "peak_busbw", Op: ">=", Value: 40},
+			},
+			result:  AcceptanceFail,
+			reasons: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			acceptance := evaluateAcceptance("", tc.thresholds, tc.status, results)
+			if acceptance.Result != tc.result || len(acceptance.Reasons) != tc.reasons {
+				t.Errorf("result = %s with reasons %v, want %s with %d reasons",
+					acceptance.Result, acceptance.Reasons, tc.result, tc.reasons)
+			}
+			if len(acceptance.Checks) != len(tc.thresholds) {
+				t.Errorf("checks = %d, want %d", len(acceptance.Checks), len(tc.thresholds))
+			}
+		})
+	}
+}
+
+func TestRenderJUnit(t *testing.T) {
+	points := []ChartDataPoint{comparePoint(1024, "float", 10)}
+	results := RunResults{ID: "a", DataPoints: points, Summary: summarizeResults(points)}
+	meta := HistoryMeta{ID: "a", Status: RunStatusSuccess}
+	meta.Acceptance = evaluateAcceptance("smoke", []Threshold{
+		{Size: "1K", Metric: "busbw", Op: ">=", Value: 5},
+		{Size: "1K", Metric: "busbw", Op: ">=", Value: 50},
+	}, meta.Status, results)
+
+	data, err := RenderJUnit(meta)
+	if err != nil {
+		t.Fatalf("RenderJUnit: %v", err)
+	}
+
+	xml := string(data)
+	for _, want := range []string{
+		`<testsuites name="nccl-tests" tests="3" failures="1">`,
+		`name="busbw &gt;= 50 at 1K"`,
+		`<failure message="failed at 1 of 1 sizes: 1K out_busbw = 10, 1K in_busbw = 10" type="Threshold">`,
+		`<property name="acceptance" value="FAIL"></property>`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("JUnit XML should contain %q:\n%s", want, xml)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// EnvAllowlistFile 环境变量白名单配置文件，位于 DataDir 下，不存在时使用内置白名单
+	EnvAllowlistFile = "env_allowlist.json"
+)
+
+// envNamePattern 环境变量名
+var envNamePattern = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+
+// reservedEnv 由专用参数字段控制的环境变量，不允许通过 env 覆盖
+var reservedEnv = map[string]string{
+	"UCX_TLS":                    "",
+	"NCCL_DEBUG":                 "enable_debug/nccl_debug_level",
+	"NCCL_IB_GID_INDEX":          "nccl_ib_gid_index",
+	"NCCL_MIN_NCHANNELS":         "nccl_min_channels",
+	"NCCL_IB_QPS_PER_CONNECTION": "nccl_ib_qps_per_connection",
+}
+
+// EnvRule 环境变量白名单规则
+type EnvRule struct {
+	Name  string `json:"name"`  // 变量名通配符，如 NCCL_IB_HCA、NCCL_IB_*
+	Value string `json:"value"` // 变量值必须完整匹配的正则表达式
+}
+
+// defaultEnvAllowlist 内置白名单，按顺序匹配，第一条名称匹配的规则生效；可通过配置文件的 env_allowlist 替换
+var defaultEnvAllowlist = []EnvRule{
+	{Name: "NCCL_ALGO", Value: `^\^?[A-Za-z]+(,[A-Za-z]+)*$`},
+	{Name: "NCCL_PROTO", Value: `^\^?[A-Za-z0-9]+(,[A-Za-z0-9]+)*$`},
+	{Name: "NCCL_IB_HCA", Value: `^[=^]{0,2}[A-Za-z0-9_.-]+(:\d+)?(,[A-Za-z0-9_.-]+(:\d+)?)*$`},
+	{Name: "NCCL_SOCKET_IFNAME", Value: `^[=^]{0,2}[A-Za-z0-9_.-]+(,[A-Za-z0-9_.-]+)*$`},
+	{Name: "NCCL_NET_GDR_LEVEL", Value: `^(LOC|NVL|PIX|PXB|PHB|SYS|\d+)$`},
+	{Name: "NCCL_P2P_LEVEL", Value: `^(LOC|NVL|PIX|PXB|PHB|SYS|\d+)$`},
+	{Name: "NCCL_CROSS_NIC", Value: `^[0-2]$`},
+	{Name: "NCCL_NET", Value: `^[A-Za-z0-9_ -]{1,64}$`},
+	{Name: "NCCL_LAUNCH_MODE", Value: `^(PARALLEL|GROUP)$`},
+	{Name: "NCCL_DEBUG_SUBSYS", Value: `^\^?[A-Za-z]+(,[A-Za-z]+)*$`},
+	{Name: "NCCL_IB_TIMEOUT", Value: `^\d{1,3}$`},
+	{Name: "NCCL_IB_RETRY_CNT", Value: `^\d{1,3}$`},
+	{Name: "NCCL_IB_SL", Value: `^\d{1,3}$`},
+	{Name: "NCCL_IB_TC", Value: `^\d{1,3}$`},
+	{Name: "NCCL_MAX_NCHANNELS", Value: `^\d{1,4}$`},
+	{Name: "NCCL_MIN_CTAS", Value: `^\d{1,4}$`},
+	{Name: "NCCL_MAX_CTAS", Value: `^\d{1,4}$`},
+	{Name: "NCCL_NTHREADS", Value: `^\d{1,4}$`},
+	{Name: "NCCL_SOCKET_NTHREADS", Value: `^\d{1,4}$`},
+	{Name: "NCCL_NSOCKS_PERTHREAD", Value: `^\d{1,4}$`},
+	{Name: "NCCL_BUFFSIZE", Value: `^\d{1,12}$`},
+	{Name: "NCCL_IB_DISABLE", Value: `^[01]$`},
+	{Name: "NCCL_IB_SPLIT_DATA_ON_QPS", Value: `^[01]$`},
+	{Name: "NCCL_IB_ADAPTIVE_ROUTING", Value: `^[01]$`},
+	{Name: "NCCL_IB_PCI_RELAXED_ORDERING", Value: `^[0-2]$`},
+	{Name: "NCCL_NET_GDR_READ", Value: `^[01]$`},
+	{Name: "NCCL_NET_SHARED_BUFFERS", Value: `^[01]$`},
+	{Name: "NCCL_P2P_DISABLE", Value: `^[01]$`},
+	{Name: "NCCL_SHM_DISABLE", Value: `^[01]$`},
+	{Name: "NCCL_NVLS_ENABLE", Value: `^[0-2]$`},
+	{Name: "NCCL_COLLNET_ENABLE", Value: `^[01]$`},
+	{Name: "NCCL_CUMEM_ENABLE", Value: `^[01]$`},
+	{Name: "NCCL_IGNORE_CPU_AFFINITY", Value: `^[01]$`},
+	{Name: "UCX_NET_DEVICES", Value: `^[A-Za-z0-9_.-]+(:\d+)?(,[A-Za-z0-9_.-]+(:\d+)?)*$`},
+	{Name: "UCX_IB_GID_INDEX", Value: `^\d{1,3}$`},
+	{Name: "UCX_IB_SL", Value: `^\d{1,3}$`},
+	{Name: "UCX_RNDV_THRESH", Value: `^(\d{1,12}[KMG]?|auto|inf)$`},
+}
+
+// deniedEnvNames 无论白名单如何配置都不允许设置的变量名通配符：
+// 插件和库路径会让每个 rank 加载任意共享库，文件路径会让服务端以自身权限读写任意文件
+var deniedEnvNames = []string{"*_PLUGIN", "*_FILE", "*_PATH", "LD_*"}
+
+// loadEnvAllowlist 读取环境变量白名单，配置文件不存在时返回内置白名单
+func loadEnvAllowlist() ([]EnvRule, error) {
+	data, err := os.ReadFile(filepath.Join(DataDir, EnvAllowlistFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return defaultEnvAllowlist, nil
+		}
+		return nil, fmt.Errorf("failed to read env allowlist: %v", err)
+	}
+
+	var rules []EnvRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse env allowlist: %v", err)
+	}
+	return rules, nil
+}
+
+// checkEnv 按白名单校验环境变量，返回字段名（env.KEY）到错误信息的映射
+func checkEnv(env map[string]string) map[string]string {
+	fields := make(map[string]string)
+	if len(env) == 0 {
+		return fields
+	}
+
+	rules, err := loadEnvAllowlist()
+	if err != nil {
+		fields["env"] = err.Error()
+		return fields
+	}
+	return checkEnvRules(env, rules)
+}
+
+// checkEnvRules 按给定的白名单规则校验环境变量
+func checkEnvRules(env map[string]string, rules []EnvRule) map[string]string {
+	fields := make(map[string]string)
+	for name, value := range env {
+		field := "env." + name
+
+		if !envNamePattern.MatchString(name) {
+			fields[field] = "invalid environment variable name"
+			continue
+		}
+		if param, ok := reservedEnv[name]; ok {
+			if param == "" {
+				fields[field] = "is managed by the server"
+			} else {
+				fields[field] = "is controlled by the " + param + " field"
+			}
+			continue
+		}
+
+		if denied := deniedEnvName(name); denied != "" {
+			fields[field] = "is not allowed (matches " + denied + ")"
+			continue
+		}
+
+		rule, ok := matchEnvRule(rules, name)
+		if !ok {
+			fields[field] = "is not in the allowlist"
+			continue
+		}
+
+		re, err := regexp.Compile(rule.Value)
+		if err != nil {
+			fields[field] = fmt.Sprintf("allowlist rule %s has an invalid value pattern", rule.Name)
+			continue
+		}
+		if !re.MatchString(value) {
+			fields[field] = fmt.Sprintf("value must match %s", rule.Value)
+		}
+	}
+
+	return fields
+}
+
+// matchEnvRule 返回第一条名称匹配的白名单规则
+func matchEnvRule(rules []EnvRule, name string) (EnvRule, bool) {
+	for _, rule := range rules {
+		if ok, _ := path.Match(rule.Name, name); ok {
+			return rule, true
+		}
+	}
+	return EnvRule{}, false
+}
+
+// deniedEnvName 返回变量名匹配的禁止通配符，不匹配时返回空字符串
+func deniedEnvName(name string) string {
+	for _, pattern := range deniedEnvNames {
+		if ok, _ := path.Match(pattern, name); ok {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// sortedEnv 将环境变量按名称排序后转换为 KEY=VALUE 列表
+func sortedEnv(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	kvs := make([]string, 0, len(names))
+	for _, name := range names {
+		kvs = append(kvs, name+"="+env[name])
+	}
+	return kvs
+}
+
+// GetEnvAllowlist 获取当前生效的环境变量白名单
+func GetEnvAllowlist(c *gin.Context) {
+	rules, err := loadEnvAllowlist()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count": len(rules),
+		"rules": rules,
+	})
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// FailureSignaturesFile 失败特征库配置文件，位于 DataDir 下，不存在时使用内置特征库
+	FailureSignaturesFile = "failure_signatures.json"
+	// maxFailureContext 特征中 context 的上限
+	maxFailureContext = 100
+)
+
+// defaultFailureSignatures 内置失败特征库，格式与 FailureSignaturesFile 相同
+//
+//go:embed failure_signatures.json
+var defaultFailureSignatures []byte
+
+// FailureSignature 已知失败的特征，按顺序匹配输出的每一行，一行只归入第一个匹配的特征
+// 节点名依次取自：pattern 中名为 host 的分组、host_pattern 在匹配行及之后 context 行中的匹配、
+// NCCL 日志行开头的节点名
+type FailureSignature struct {
+	ID          string `json:"id"`
+	Category    string `json:"category"` // 失败类别，如 ssh, launch, network, cuda
+	Description string `json:"description"`
+	Pattern     string `json:"pattern"`
+	HostPattern string `json:"host_pattern,omitempty"`
+	Context     int    `json:"context,omitempty"`
+	Hint        string `json:"hint"` // 处理建议
+
+	pattern     *regexp.Regexp
+	hostPattern *regexp.Regexp
+}
+
+// Failure 运行输出中匹配到的一类失败
+type Failure struct {
+	Signature   string   `json:"signature"`
+	Category    string   `json:"category"`
+	Description string   `json:"description"`
+	Hosts       []string `json:"hosts"` // 涉及的节点，无法识别时为空
+	Hint        string   `json:"hint"`
+	Count       int      `json:"count"` // 匹配的行数
+	Line        int      `json:"line"`  // 第一次匹配的行号，从 1 开始
+	Text        string   `json:"text"`  // 第一次匹配的行
+}
+
+// loadFailureSignatures 读取失败特征库，配置文件不存在时返回内置特征库
+func loadFailureSignatures() ([]FailureSignature, error) {
+	data, err := os.ReadFile(filepath.Join(DataDir, FailureSignaturesFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read failure signatures: %v", err)
+		}
+		data = defaultFailureSignatures
+	}
+	return parseFailureSignatures(data)
+}
+
+// parseFailureSignatures 解析并编译失败特征库
+func parseFailureSignatures(data []byte) ([]FailureSignature, error) {
+	var signatures []FailureSignature
+	if err := json.Unmarshal(data, &signatures); err != nil {
+		return nil, fmt.Errorf("failed to parse failure signatures: %v", err)
+	}
+
+	for i := range signatures {
+		s := &signatures[i]
+		if s.ID == "" || s.Category == "" || s.Pattern == "" {
+			return nil, fmt.Errorf("failure signature %d: id, category and pattern are required", i)
+		}
+		if s.Context < 0 || s.Context > maxFailureContext {
+			return nil, fmt.Errorf("failure signature %s: context must be between 0 and %d", s.ID, maxFailureContext)
+		}
+
+		var err error
+		if s.pattern, err = regexp.Compile(s.Pattern); err != nil {
+			return nil, fmt.Errorf("failure signature %s: invalid pattern: %v", s.ID, err)
+		}
+		if s.HostPattern != "" {
+			if s.hostPattern, err = regexp.Compile(s.HostPattern); err != nil {
+				return nil, fmt.Errorf("failure signature %s: invalid host_pattern: %v", s.ID, err)
+			}
+		}
+	}
+	return signatures, nil
+}
+
+// ClassifyFailures 按特征库扫描输出，返回匹配到的失败，按第一次出现的顺序排列
+func ClassifyFailures(lines []string, signatures []FailureSignature) []Failure {
+	var failures []Failure
+	index := make(map[string]int) // 特征 ID -> failures 中的位置
+
+	for i, line := range lines {
+		for _, s := range signatures {
+			m := s.pattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+
+			n, ok := index[s.ID]
+			if !ok {
+				n = len(failures)
+				index[s.ID] = n
+				failures = append(failures, Failure{
+					Signature:   s.ID,
+					Category:    s.Category,
+					Description: s.Description,
+					Hosts:       []string{},
+					Hint:        s.Hint,
+					Line:        i + 1,
+					Text:        line,
+				})
+			}
+			failure := &failures[n]
+			failure.Count++
+
+			for _, host := range failureHosts(s, m, lines, i) {
+				if !slices.Contains(failure.Hosts, host) {
+					failure.Hosts = append(failure.Hosts, host)
+				}
+			}
+			break
+		}
+	}
+	return failures
+}
+
+// failureHosts 提取一次匹配涉及的节点
+func failureHosts(s FailureSignature, m []string, lines []string, i int) []string {
+	var hosts []string
+	hosts = append(hosts, namedGroups(s.pattern, m, "host")...)
+
+	if s.hostPattern != nil {
+		for j := i; j < len(lines) && j <= i+s.Context; j++ {
+			for _, hm := range s.hostPattern.FindAllStringSubmatch(lines[j], -1) {
+				hosts = append(hosts, namedGroups(s.hostPattern, hm, "host")...)
+			}
+		}
+	}
+
+	if len(hosts) == 0 {
+		if entry, ok := parseLogLine(lines[i]); ok {
+			hosts = append(hosts, entry.Host)
+		}
+	}
+	return hosts
+}
+
+// namedGroups 返回所有名为 name 且匹配到内容的分组，同一个名字可以出现在多个分支中
+func namedGroups(re *regexp.Regexp, m []string, name string) []string {
+	var values []string
+	for i, group := range re.SubexpNames() {
+		if group == name && i < len(m) && m[i] != "" {
+			values = append(values, m[i])
+		}
+	}
+	return values
+}
+
+// classifyRunFailures 运行失败时扫描输出，特征库无法读取时只记录日志
+func classifyRunFailures(id string, lines []string) []Failure {
+	signatures, err := loadFailureSignatures()
+	if err != nil {
+		fmt.Printf("Run %s: %v\n", id, err)
+		return nil
+	}
+	return ClassifyFailures(lines, signatures)
+}
+
+// GetFailureSignatures 获取当前生效的失败特征库
+func GetFailureSignatures(c *gin.Context) {
+	signatures, err := loadFailureSignatures()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":      len(signatures),
+		"signatures": signatures,
+	})
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassifyFailures(t *testing.T) {
+	signatures, err := parseFailureSignatures(defaultFailureSignatures)
+	if err != nil {
+		t.Fatalf("built-in signatures: %v", err)
+	}
+
+	testCases := []struct {
+		desc     string
+		output   []string
+		category string
+		hosts    []string
+		count    int
+	}{
+		{
+			desc:     "SSH 无法连接",
+			output:   []string{"ssh: connect to host node03 port 22: No route to host"},
+			category: "ssh",
+			hosts:    []string{"node03"},
+			count:    1,
+		},
+		{
+			desc: "ORTE 守护进程丢失",
+			output: []string{
+				"--------------------------------------------------------------------------",
+				"ORTE has lost communication with a remote daemon.",
+				"",
+				"  HNP daemon   : [[41306,0],0] on node node01",
+				"  Remote daemon: [[41306,0],2] on node node03",
+			},
+			category: "launch",
+			hosts:    []string{"node01", "node03"},
+			count:    1,
+		},
+		{
+			desc: "NCCL IB 错误",
+			output: []string{
+				"node02:1201:1288 [3] NCCL WARN NET/IB : Got completion from peer 10.0.0.1<33642> with error 12",
+				"node02:1201:1288 [4] NCCL WARN NET/IB : Got completion from peer 10.0.0.1<33642> with error 12",
+				"node04:1201:1288 [0] NCCL WARN NET/IB : mlx5_2:1 Got async event : port error",
+			},
+			category: "network",
+			hosts:    []string{"node02", "node04"},
+			count:    3,
+		},
+		{
+			desc:     "nccl-tests 报告 unhandled system error",
+			output:   []string{" node05: Test NCCL failure common.cu:958 'unhandled system error (run with NCCL_DEBUG=INFO for details) / '"},
+			category: "nccl",
+			hosts:    []string{"node05"},
+			count:    1,
+		},
+		{
+			desc:     "CUDA 错误",
+			output:   []string{"node01:100:100 [2] NCCL WARN Cuda failure 'out of memory'"},
+			category: "cuda",
+			hosts:    []string{"node01"},
+			count:    1,
+		},
+		{
+			desc:     "slot 不足",
+			output:   []string{"There are not enough slots available in the system to satisfy the 16"},
+			category: "hostfile",
+			hosts:    []string{},
+			count:    1,
+		},
+		{
+			desc:     "启动器无法执行",
+			output:   []string{`Failed to start command: exec: "mpirun": executable file not found in $PATH`},
+			category: "launch",
+			hosts:    []string{},
+			count:    1,
+		},
+		{
+			desc:     "进程崩溃",
+			output:   []string{"mpirun noticed that process rank 9 with PID 0 on node node02 exited on signal 11 (Segmentation fault)."},
+			category: "crash",
+			hosts:    []string{"node02"},
+			count:    1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			failures := ClassifyFailures(tc.output, signatures)
+			if len(failures) != 1 {
+				t.Fatalf("failures = %+v, want 1", failures)
+			}
+			f := failures[0]
+			if f.Category != tc.category || !reflect.DeepEqual(f.Hosts, tc.hosts) || f.Count != tc.count || f.Hint == "" {
+				t.Errorf("failure = %+v, want category %s hosts %v count %d", f, tc.category, tc.hosts, tc.count)
+			}
+		})
+	}
+
+	if failures := ClassifyFailures([]string{"# Avg bus bandwidth    : 120.5"}, signatures); len(failures) != 0 {
+		t.Errorf("unexpected failures: %+v", failures)
+	}
+}
+
+func TestParseFailureSignatures(t *testing.T) {
+	testCases := []struct {
+		desc string
+		data string
+		ok   bool
+	}{
+		{desc: "合法", data: `[{"id":"a","category":"x","pattern":"boom","host_pattern":"on (?P<host>\\S+)","context":3}]`, ok: true},
+		{desc: "缺少 pattern", data: `[{"id":"a","category":"x"}]`},
+		{desc: "正则不合法", data: `[{"id":"a","category":"x","pattern":"("}]`},
+		{desc: "context 超出范围", data: `[{"id":"a","category":"x","pattern":"boom","context":1000}]`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := parseFailureSignatures([]byte(tc.data))
+			if (err == nil) != tc.ok {
+				t.Errorf("err = %v, want ok=%v", err, tc.ok)
+			}
+		})
+	}
+}
@@ -1,64 +1,215 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// HistoryDir 历史记录存储目录，可通过配置文件设置
+var HistoryDir = "data/history"
+
+// 历史记录文件：<id>.txt 为命令和输出文本，<id>.json 为元数据，<id>.results.json 为解析结果
+// 旧版本只保存 YYYYMMDD_HHMMSS.txt，没有元数据
 const (
-	// HistoryDir 历史记录存储目录
-	HistoryDir = "data/history"
+	historyOutputExt = ".txt"
+	historyMetaExt   = ".json"
 )
 
 // HistoryRecord 历史记录信息
 type HistoryRecord struct {
-	Filename string    `json:"filename"`
-	Modified time.Time `json:"modified"`
+	ID         string       `json:"id"`
+	Filename   string       `json:"filename"`
+	Modified   time.Time    `json:"modified"`
+	Verdict    string       `json:"verdict,omitempty"`    // 与基准对比的结论：ok, degraded, regressed
+	Acceptance string       `json:"acceptance,omitempty"` // 验收结论：PASS 或 FAIL
+	Metadata   *HistoryMeta `json:"metadata,omitempty"`   // 旧版记录没有元数据
 }
 
 // HistoryContent 历史记录内容
 type HistoryContent struct {
-	Output string `json:"output"`
-	Status string `json:"status"`
+	ID       string       `json:"id"`
+	Output   string       `json:"output"`
+	Metadata *HistoryMeta `json:"metadata,omitempty"`
+	Status   string       `json:"status"`
 }
 
-// SaveHistoryAsync 异步保存测试历史数据
-func SaveHistoryAsync(output string) {
-	go func() {
-		if err := saveHistoryFile(output); err != nil {
-			fmt.Printf("Failed to save history: %v\n", err)
-		}
-	}()
+// HistoryMeta 历史记录元数据，记录运行是如何启动的以及运行结果
+type HistoryMeta struct {
+	ID         string         `json:"id"` // 与运行 ID 相同
+	Owner      string         `json:"owner"`
+	Status     string         `json:"status"` // 运行结束时的状态：success, error, timeout, stopped
+	Error      string         `json:"error,omitempty"`
+	ExitCode   int            `json:"exit_code"`
+	Stopped    bool           `json:"stopped"`
+	TimedOut   bool           `json:"timed_out"`
+	Launcher   string         `json:"launcher"`
+	Command    string         `json:"command"`
+	Argv       []string       `json:"argv"`
+	Env        []string       `json:"env"` // 传递给所有 rank 的实际环境变量
+	Params     NCCLTestParams `json:"params"`
+	IPListFile string         `json:"iplist_file"`
+	Hosts      []string       `json:"hosts"` // 运行时 IP 列表文件的内容快照
+	Timeout    int            `json:"timeout"`
+
+	OutputLines     int        `json:"output_lines"`
+	CreatedAt       time.Time  `json:"created_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	DurationSeconds float64    `json:"duration_seconds"` // 从开始运行到结束的时长，不含排队时间
+
+	Header   *RunHeader       `json:"header,omitempty"`   // 输出开头的运行信息：集合通信、rank 数、NCCL/CUDA 版本等
+	Topology *TopologyReport  `json:"topology,omitempty"` // 设备列表检查结果：缺失的 rank、GPU 数量、型号等
+	Summary  *ResultSummary   `json:"summary,omitempty"`  // 结果汇总，完整结果见 /history/:id/results
+	Logs     *LogStats        `json:"logs,omitempty"`     // NCCL 调试日志按级别和节点的统计，查询见 /runs/:id/logs
+	Verdict  *BaselineVerdict `json:"verdict,omitempty"`  // 与匹配的基准运行对比的结论，没有匹配的基准时为空
+
+	Thresholds []Threshold       `json:"thresholds,omitempty"` // 运行时展开的验收阈值
+	Acceptance *AcceptanceResult `json:"acceptance,omitempty"` // 验收结果，JUnit 格式见 /history/:id/junit
+	Failures   []Failure         `json:"failures,omitempty"`   // 按失败特征库识别的失败原因
 }
 
-// saveHistoryFile 保存历史文件（同步操作，在 goroutine 中运行）
-func saveHistoryFile(output string) error {
+// newHistoryMeta 根据运行信息生成历史记录元数据
+func newHistoryMeta(info RunInfo, spec *LaunchSpec) HistoryMeta {
+	meta := HistoryMeta{
+		ID:          info.ID,
+		Owner:       info.Owner,
+		Status:      info.Status,
+		Error:       info.Error,
+		ExitCode:    info.ExitCode,
+		Stopped:     info.Status == RunStatusStopped,
+		TimedOut:    info.Status == RunStatusTimeout,
+		Launcher:    info.Launcher,
+		Command:     info.Command,
+		Env:         info.Env,
+		Params:      info.Params,
+		IPListFile:  info.Params.IPListFile,
+		Hosts:       info.Hosts,
+		Timeout:     info.Timeout,
+		OutputLines: info.OutputLines,
+		CreatedAt:   info.CreatedAt,
+		StartedAt:   info.StartedAt,
+		FinishedAt:  info.FinishedAt,
+		Thresholds:  info.Thresholds,
+		Acceptance:  info.Acceptance,
+		Failures:    info.Failures,
+	}
+	if spec != nil {
+		meta.Argv = spec.Argv
+	}
+	if info.StartedAt != nil && info.FinishedAt != nil {
+		meta.DurationSeconds = info.FinishedAt.Sub(*info.StartedAt).Seconds()
+	}
+	return meta
+}
+
+// saveHistoryFile 保存历史文件（同步操作，由 RunManager 在后台任务中调用）
+func saveHistoryFile(meta HistoryMeta, output string) error {
 	// 创建历史目录
 	if err := os.MkdirAll(HistoryDir, 0755); err != nil {
 		return fmt.Errorf("failed to create history directory: %v", err)
 	}
 
-	// 生成文件名：20251120_143022.txt
-	timestamp := time.Now().Format("20060102_150405")
-	filename := filepath.Join(HistoryDir, timestamp+".txt")
-
-	// 保存文件
+	// 文件名使用运行 ID：20251120_143022_a1b2c3.txt，同一秒内的运行不会互相覆盖
+	filename := filepath.Join(HistoryDir, meta.ID+historyOutputExt)
 	if err := os.WriteFile(filename, []byte(output), 0644); err != nil {
 		return fmt.Errorf("failed to write history file: %v", err)
 	}
 
+	// 保存时解析结果，汇总写入元数据
+	results := ParseRunResults(meta.ID, output)
+	results.Topology = CheckTopology(results.Devices, meta.Hosts, meta.Params)
+	if err := writeHistoryResults(results); err != nil {
+		return err
+	}
+	meta.Header = results.Header
+	meta.Topology = results.Topology
+	meta.Summary = &results.Summary
+	// NCCL 日志只在保存时解析一次，/runs/:id/logs 直接查询保存的结果
+	logs := parseLogEntries(strings.Split(strings.TrimPrefix(output, meta.Command+"\n\n"), "\n"))
+	meta.Logs = summarizeLogs(logs)
+	if len(logs) > 0 {
+		if err := writeHistoryLogs(meta.ID, logs); err != nil {
+			return err
+		}
+	}
+
+	// 与相同节点集合和参数的基准运行对比
+	verdict, err := evaluateBaseline(meta, results)
+	if err != nil {
+		fmt.Printf("Failed to evaluate baseline for %s: %v\n", meta.ID, err)
+	}
+	meta.Verdict = verdict
+
+	if err := writeHistoryMeta(meta); err != nil {
+		return err
+	}
+
 	fmt.Printf("History saved to %s\n", filename)
 	return nil
 }
 
+// writeHistoryMeta 写入元数据文件，先写临时文件再重命名，避免读到不完整的内容
+func writeHistoryMeta(meta HistoryMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode history metadata: %v", err)
+	}
+
+	filename := filepath.Join(HistoryDir, meta.ID+historyMetaExt)
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write history metadata: %v", err)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write history metadata: %v", err)
+	}
+	return nil
+}
+
+// loadHistoryMeta 读取历史记录元数据，旧版记录没有元数据时返回 nil
+func loadHistoryMeta(id string) (*HistoryMeta, error) {
+	data, err := os.ReadFile(filepath.Join(HistoryDir, id+historyMetaExt))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var meta HistoryMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse history metadata: %v", err)
+	}
+	return &meta, nil
+}
+
+// historyID 从路由参数中解析历史记录 ID，兼容旧版带 .txt 后缀的文件名
+func historyID(param string) (string, bool) {
+	id := strings.TrimSuffix(param, historyOutputExt)
+	if id == "" || !filenamePattern.MatchString(id) {
+		return "", false
+	}
+	return id, true
+}
+
 // GetHistoryList 获取历史记录列表
 func GetHistoryList(c *gin.Context) {
+	filter, err := newHistoryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 读取历史目录
 	entries, err := os.ReadDir(HistoryDir)
 	if err != nil {
@@ -83,8 +234,8 @@ func GetHistoryList(c *gin.Context) {
 		if entry.IsDir() {
 			continue
 		}
-		// 只收集 .txt 文件
-		if filepath.Ext(entry.Name()) != ".txt" {
+		// 只收集 .txt 文件，元数据从同名 .json 文件读取
+		if filepath.Ext(entry.Name()) != historyOutputExt {
 			continue
 		}
 
@@ -93,10 +244,28 @@ func GetHistoryList(c *gin.Context) {
 			continue
 		}
 
-		records = append(records, HistoryRecord{
+		id := strings.TrimSuffix(entry.Name(), historyOutputExt)
+		meta, err := loadHistoryMeta(id)
+		if err != nil {
+			fmt.Printf("Failed to load history metadata %s: %v\n", id, err)
+		}
+		if !filter.matches(meta) {
+			continue
+		}
+
+		record := HistoryRecord{
+			ID:       id,
 			Filename: entry.Name(),
 			Modified: info.ModTime(),
-		})
+			Metadata: meta,
+		}
+		if meta != nil && meta.Verdict != nil {
+			record.Verdict = meta.Verdict.Verdict
+		}
+		if meta != nil && meta.Acceptance != nil {
+			record.Acceptance = meta.Acceptance.Result
+		}
+		records = append(records, record)
 	}
 
 	// 按修改时间倒序排列（最新的在前）
@@ -111,33 +280,73 @@ func GetHistoryList(c *gin.Context) {
 	})
 }
 
-// GetHistoryContent 获取指定历史记录的内容
-func GetHistoryContent(c *gin.Context) {
-	filename := c.Param("filename")
+// historyFilter 历史记录列表的过滤条件，为空的条件不过滤
+type historyFilter struct {
+	Collective  string
+	WorldSize   int
+	NCCLVersion string // 支持前缀匹配，如 2.27 匹配 2.27.7
+	CUDAVersion string
+}
 
-	// 安全检查：防止路径遍历攻击
-	if filename == "" || filepath.Dir(filename) != "." {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid filename",
-		})
-		return
+// newHistoryFilter 从查询参数 collective, world_size, nccl_version, cuda_version 解析过滤条件
+func newHistoryFilter(c *gin.Context) (historyFilter, error) {
+	filter := historyFilter{
+		Collective:  c.Query("collective"),
+		NCCLVersion: c.Query("nccl_version"),
+		CUDAVersion: c.Query("cuda_version"),
+	}
+	if v := c.Query("world_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return filter, fmt.Errorf("invalid world_size: %s", v)
+		}
+		filter.WorldSize = n
+	}
+	return filter, nil
+}
+
+// matches 检查历史记录是否满足过滤条件，设置了条件时没有元数据的旧版记录不匹配
+func (f historyFilter) matches(meta *HistoryMeta) bool {
+	if f == (historyFilter{}) {
+		return true
+	}
+	if meta == nil {
+		return false
 	}
 
-	// 构建完整路径
-	filePath := filepath.Join(HistoryDir, filename)
+	var header RunHeader
+	if meta.Header != nil {
+		header = *meta.Header
+	}
+	collective := header.Collective
+	if collective == "" {
+		collective = meta.Params.Collective
+	}
+
+	return (f.Collective == "" || f.Collective == collective) &&
+		(f.WorldSize == 0 || f.WorldSize == header.WorldSize) &&
+		versionMatches(f.NCCLVersion, header.NCCLVersion) &&
+		versionMatches(f.CUDAVersion, header.CUDAVersion)
+}
+
+// versionMatches 检查版本号是否匹配，want 可以是完整版本或前缀，如 2.27 匹配 2.27.7 但不匹配 2.270
+func versionMatches(want, version string) bool {
+	return want == "" || version == want || strings.HasPrefix(version, want+".")
+}
 
-	// 验证文件确实在 HistoryDir 下
-	absPath, _ := filepath.Abs(filePath)
-	absHistoryDir, _ := filepath.Abs(HistoryDir)
-	if !filepath.HasPrefix(absPath, absHistoryDir) {
+// GetHistoryContent 获取指定历史记录的内容和元数据
+func GetHistoryContent(c *gin.Context) {
+	// 安全检查：ID 只能包含文件名字符，防止路径遍历攻击
+	id, ok := historyID(c.Param("id"))
+	if !ok {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid file path",
+			"error": "Invalid history ID",
 		})
 		return
 	}
 
 	// 读取文件
-	data, err := os.ReadFile(filePath)
+	data, err := os.ReadFile(filepath.Join(HistoryDir, id+historyOutputExt))
 	if err != nil {
 		if os.IsNotExist(err) {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -151,39 +360,35 @@ func GetHistoryContent(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"output": string(data),
-		"status": "success",
-	})
-}
-
-// DeleteHistory 删除指定的历史记录
-func DeleteHistory(c *gin.Context) {
-	filename := c.Param("filename")
-
-	// 安全检查：防止路径遍历攻击
-	if filename == "" || filepath.Dir(filename) != "." {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid filename",
+	meta, err := loadHistoryMeta(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
 		})
 		return
 	}
 
-	// 构建完整路径
-	filePath := filepath.Join(HistoryDir, filename)
+	c.JSON(http.StatusOK, HistoryContent{
+		ID:       id,
+		Output:   string(data),
+		Metadata: meta,
+		Status:   "success",
+	})
+}
 
-	// 验证文件确实在 HistoryDir 下
-	absPath, _ := filepath.Abs(filePath)
-	absHistoryDir, _ := filepath.Abs(HistoryDir)
-	if !filepath.HasPrefix(absPath, absHistoryDir) {
+// DeleteHistory 删除指定的历史记录（输出、元数据和解析结果）
+func DeleteHistory(c *gin.Context) {
+	// 安全检查：ID 只能包含文件名字符，防止路径遍历攻击
+	id, ok := historyID(c.Param("id"))
+	if !ok {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid file path",
+			"error": "Invalid history ID",
 		})
 		return
 	}
 
 	// 删除文件
-	if err := os.Remove(filePath); err != nil {
+	if err := os.Remove(filepath.Join(HistoryDir, id+historyOutputExt)); err != nil {
 		if os.IsNotExist(err) {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error": "History record not found",
@@ -195,6 +400,11 @@ func DeleteHistory(c *gin.Context) {
 		})
 		return
 	}
+	for _, ext := range []string{historyMetaExt, historyResultsExt, historyLogsExt} {
+		if err := os.Remove(filepath.Join(HistoryDir, id+ext)); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Failed to delete history file %s: %v\n", id+ext, err)
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "History record deleted successfully",
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryID(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		param string
+		id    string
+		ok    bool
+	}{
+		{desc: "运行 ID", param: "20251120_143022_a1b2c3", id: "20251120_143022_a1b2c3", ok: true},
+		{desc: "旧版文件名", param: "20251120_143022.txt", id: "20251120_143022", ok: true},
+		{desc: "路径遍历", param: "../iplist/default", ok: false},
+		{desc: "空", param: ".txt", ok: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			id, ok := historyID(tc.param)
+			if ok != tc.ok || id != tc.id {
+				t.Errorf("historyID(%q) = %q, %v; want %q, %v", tc.param, id, ok, tc.id, tc.ok)
+			}
+		})
+	}
+}
+
+func TestSaveHistoryMeta(t *testing.T) {
+	oldDir := HistoryDir
+	HistoryDir = t.TempDir()
+	defer func() { HistoryDir = oldDir }()
+
+	started := time.Date(2025, 11, 20, 14, 30, 22, 0, time.UTC)
+	finished := started.Add(90 * time.Second)
+	info := RunInfo{
+		ID:         "20251120_143022_a1b2c3",
+		Status:     RunStatusTimeout,
+		Launcher:   LauncherMPIRun,
+		Params:     validParams(),
+		Hosts:      []string{"10.0.0.1 slots=8", "10.0.0.2 slots=8"},
+		StartedAt:  &started,
+		FinishedAt: &finished,
+	}
+	spec := &LaunchSpec{Argv: []string{MPIRunPath, "--hostfile", "hostfile"}}
+
+	if err := saveHistoryFile(newHistoryMeta(info, spec), "output"); err != nil {
+		t.Fatalf("saveHistoryFile: %v", err)
+	}
+
+	meta, err := loadHistoryMeta(info.ID)
+	if err != nil || meta == nil {
+		t.Fatalf("loadHistoryMeta: %v, %v", meta, err)
+	}
+	if !meta.TimedOut || meta.Stopped || meta.DurationSeconds != 90 {
+		t.Errorf("unexpected status fields: %+v", meta)
+	}
+	if meta.IPListFile != "default" || len(meta.Hosts) != 2 || len(meta.Argv) != 3 {
+		t.Errorf("unexpected launch fields: %+v", meta)
+	}
+
+	// 旧版记录只有输出文件，没有元数据
+	if err := os.WriteFile(filepath.Join(HistoryDir, "20251120_143022.txt"), []byte("legacy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if meta, err := loadHistoryMeta("20251120_143022"); meta != nil || err != nil {
+		t.Errorf("legacy record should have no metadata, got %v, %v", meta, err)
+	}
+}
+
+func TestHistoryFilter(t *testing.T) {
+	params := validParams()
+	params.Collective = "all_reduce"
+	meta := &HistoryMeta{
+		Params: params,
+		Header: &RunHeader{WorldSize: 16, NCCLVersion: "2.27.7", CUDAVersion: "12.4"},
+	}
+
+	testCases := []struct {
+		desc   string
+		filter historyFilter
+		meta   *HistoryMeta
+		want   bool
+	}{
+		{desc: "没有过滤条件", filter: historyFilter{}, meta: nil, want: true},
+		{desc: "旧版记录没有元数据", filter: historyFilter{Collective: "all_reduce"}, meta: nil, want: false},
+		{desc: "集合通信取自参数", filter: historyFilter{Collective: "all_reduce"}, meta: meta, want: true},
+		{desc: "集合通信不同", filter: historyFilter{Collective: "all_gather"}, meta: meta, want: false},
+		{desc: "rank 数", filter: historyFilter{WorldSize: 16}, meta: meta, want: true},
+		{desc: "NCCL 版本前缀", filter: historyFilter{NCCLVersion: "2.27"}, meta: meta, want: true},
+		{desc: "NCCL 版本前缀不按字符匹配", filter: historyFilter{NCCLVersion: "2.2"}, meta: meta, want: false},
+		{desc: "CUDA 版本不同", filter: historyFilter{CUDAVersion: "12.2"}, meta: meta, want: false},
+		{desc: "多个条件", filter: historyFilter{Collective: "all_reduce", WorldSize: 16, CUDAVersion: "12"}, meta: meta, want: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := tc.filter.matches(tc.meta); got != tc.want {
+				t.Errorf("matches = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHistoryLogEntries(t *testing.T) {
+	oldDir := HistoryDir
+	HistoryDir = t.TempDir()
+	defer func() { HistoryDir = oldDir }()
+
+	info := RunInfo{ID: "20251120_143022_d4e5f6", Status: RunStatusError, Command: "mpirun nccl_test", Params: validParams()}
+	output := info.Command + "\n\n" + strings.Join([]string{
+		"node01:100:100 [0] NCCL INFO Bootstrap : Using bond0:10.0.0.1<0>",
+		"node02:200:210 [1] NCCL WARN Call to ibv_modify_qp failed",
+	}, "\n")
+	if err := saveHistoryFile(newHistoryMeta(info, nil), output); err != nil {
+		t.Fatalf("saveHistoryFile: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(HistoryDir, info.ID+historyLogsExt)); err != nil {
+		t.Fatalf("logs should be saved with the record: %v", err)
+	}
+
+	// 查询使用保存的解析结果，不再读取输出
+	if err := os.WriteFile(filepath.Join(HistoryDir, info.ID+historyOutputExt), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	entries, finished, err := runLogEntries(info.ID)
+	if err != nil || !finished || len(entries) != 2 || entries[1].Line != 2 || entries[1].Level != "WARN" {
+		t.Errorf("entries = %+v, finished = %v, err = %v", entries, finished, err)
+	}
+
+	// 没有 NCCL 日志的记录不生成日志文件
+	info.ID = "20251120_143022_a7b8c9"
+	if err := saveHistoryFile(newHistoryMeta(info, nil), info.Command+"\n\n# Avg bus bandwidth : 1"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _, err := runLogEntries(info.ID); err != nil || len(entries) != 0 {
+		t.Errorf("entries = %+v, err = %v", entries, err)
+	}
+}
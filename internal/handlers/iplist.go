@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -11,9 +12,10 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// DataDir 数据存储目录，可通过配置文件设置
+var DataDir = "./data"
+
 const (
-	// DataDir 数据存储目录
-	DataDir = "./data"
 	// IPListDir IP列表存储目录
 	IPListDir = "iplist"
 	// IPListFileName IP列表文件名（已弃用，保留用于向后兼容）
@@ -362,3 +364,27 @@ func DeleteIPList(c *gin.Context) {
 		"message": "IP list deleted successfully",
 	})
 }
+
+// loadIPList 读取指定的 IP 列表文件，返回过滤空行后的节点列表
+func loadIPList(filename string) ([]string, error) {
+	// 安全检查：防止路径遍历
+	if filename == "" || filepath.Dir(filename) != "." {
+		return nil, fmt.Errorf("invalid iplist filename: %q", filename)
+	}
+
+	data, err := os.ReadFile(filepath.Join(DataDir, IPListDir, filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("iplist file %s does not exist", filename)
+		}
+		return nil, fmt.Errorf("failed to read iplist file %s: %v", filename, err)
+	}
+
+	var ipList []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			ipList = append(ipList, trimmed)
+		}
+	}
+	return ipList, nil
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JUnitTestSuites JUnit XML 根节点
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+// JUnitTestSuite 一次运行对应一个 testsuite
+type JUnitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []JUnitProperty `xml:"properties>property"`
+	TestCases  []JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitProperty testsuite 属性
+type JUnitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// JUnitTestCase 运行状态和每个验收阈值各对应一个 testcase
+type JUnitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *JUnitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// JUnitFailure testcase 失败信息
+type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// RenderJUnit 将运行的验收结果渲染为 JUnit XML
+// 运行状态始终作为第一个 testcase，未指定验收标准时只有这一个 testcase
+func RenderJUnit(meta HistoryMeta) ([]byte, error) {
+	className := "nccl-tests." + meta.Params.Collective
+	if meta.Params.Collective == "" {
+		className = "nccl-tests.nccl_test"
+	}
+	duration := fmt.Sprintf("%.3f", meta.DurationSeconds)
+
+	suite := JUnitTestSuite{
+		Name: "nccl-tests." + meta.ID,
+		Time: duration,
+		Properties: []JUnitProperty{
+			{Name: "run_id", Value: meta.ID},
+			{Name: "status", Value: meta.Status},
+			{Name: "command", Value: meta.Command},
+			{Name: "iplist_file", Value: meta.IPListFile},
+			{Name: "hosts", Value: strings.Join(meta.Hosts, ",")},
+		},
+	}
+	if meta.StartedAt != nil {
+		suite.Timestamp = meta.StartedAt.Format("2006-01-02T15:04:05")
+	}
+
+	status := JUnitTestCase{
+		ClassName: className,
+		Name:      "run status",
+		Time:      duration,
+		SystemOut: fmt.Sprintf("status %s, exit code %d", meta.Status, meta.ExitCode),
+	}
+	if meta.Status != RunStatusSuccess {
+		message := fmt.Sprintf("run finished with status %s", meta.Status)
+		status.Failure = &JUnitFailure{Message: message, Type: "RunStatus", Text: meta.Error}
+	}
+	suite.TestCases = append(suite.TestCases, status)
+
+	if meta.Acceptance != nil {
+		suite.Properties = append(suite.Properties,
+			JUnitProperty{Name: "acceptance", Value: meta.Acceptance.Result},
+			JUnitProperty{Name: "criteria", Value: meta.Acceptance.Criteria},
+		)
+		for _, check := range meta.Acceptance.Checks {
+			tc := JUnitTestCase{
+				ClassName: className + ".thresholds",
+				Name:      check.Name,
+				Time:      "0",
+				SystemOut: check.Message,
+			}
+			if !check.Passed {
+				tc.Failure = &JUnitFailure{Message: check.Message, Type: "Threshold", Text: check.Name + ": " + check.Message}
+			}
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+	}
+
+	for _, tc := range suite.TestCases {
+		suite.Tests++
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+	}
+
+	data, err := xml.MarshalIndent(JUnitTestSuites{
+		Name:     "nccl-tests",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []JUnitTestSuite{suite},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// GetHistoryJUnit 以 JUnit XML 格式返回运行的验收结果，供 CI 使用
+// 历史记录尚未保存时使用已结束的内存中运行
+func GetHistoryJUnit(c *gin.Context) {
+	id, ok := historyID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid history ID",
+		})
+		return
+	}
+
+	src, err := loadCompareSource(id)
+	switch {
+	case err == errCompareRunNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("run %s not found", id)})
+		return
+	case err == errCompareRunNotFinished:
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("run %s has not finished", id)})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	case src.meta == nil:
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("history record %s has no metadata", id)})
+		return
+	}
+
+	data, err := RenderJUnit(*src.meta)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", data)
+}
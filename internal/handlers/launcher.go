@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 可执行文件路径，可通过配置文件设置
+var (
+	// MPIRunPath Open MPI mpirun 路径
+	MPIRunPath = "/usr/local/sihpc/bin/mpirun"
+	// SrunPath Slurm srun 路径
+	SrunPath = "srun"
+	// SallocPath Slurm salloc 路径
+	SallocPath = "salloc"
+	// NCCLTestsDir nccl-tests 安装目录，包含 <collective>_perf 二进制
+	NCCLTestsDir = "/usr/local/sihpc/libexec/nccl-tests"
+	// NCCLTestPath nccl-tests 启动脚本路径，未指定集合通信类型时使用
+	NCCLTestPath = NCCLTestsDir + "/nccl_test"
+)
+
+const (
+	// RunsDir 运行工作目录（hostfile 等临时文件），位于 DataDir 下
+	RunsDir = "runs"
+)
+
+// 启动器名称
+const (
+	LauncherMPIRun = "mpirun"
+	LauncherSrun   = "srun"   // IP 列表需为 Slurm 节点名
+	LauncherSalloc = "salloc" // IP 列表需为 Slurm 节点名
+	LauncherFake   = "fake"
+)
+
+// DefaultLauncher 未指定启动器时使用的默认启动器，可通过配置文件的 defaults.launcher 设置
+var DefaultLauncher = LauncherMPIRun
+
+// LaunchSpec 启动器生成的待执行进程
+type LaunchSpec struct {
+	Argv    []string // 进程参数，Argv[0] 为可执行文件
+	Env     []string // 追加到服务进程环境变量之后的 KEY=VALUE
+	Command string   // 用于展示的命令文本
+}
+
+// Launcher 启动器：根据测试参数和节点列表生成要执行的进程
+type Launcher interface {
+	// Name 启动器名称
+	Name() string
+	// Prepare 生成待执行的进程，workDir 为本次运行的工作目录，可用于写入 hostfile 等文件
+	Prepare(params NCCLTestParams, hosts []string, workDir string) (*LaunchSpec, error)
+}
+
+// launchers 已注册的启动器
+var launchers = map[string]Launcher{}
+
+// RegisterLauncher 注册启动器，同名启动器会被覆盖
+func RegisterLauncher(l Launcher) {
+	launchers[l.Name()] = l
+}
+
+// getLauncher 根据名称获取启动器，名称为空时使用默认启动器
+func getLauncher(name string) (Launcher, error) {
+	if name == "" {
+		name = DefaultLauncher
+	}
+	l, ok := launchers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown launcher: %s", name)
+	}
+	return l, nil
+}
+
+// launcherNames 返回所有已注册启动器的名称
+func launcherNames() []string {
+	names := make([]string, 0, len(launchers))
+	for name := range launchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterLauncher(&MPIRunLauncher{})
+	RegisterLauncher(&SlurmLauncher{UseSalloc: false})
+	RegisterLauncher(&SlurmLauncher{UseSalloc: true})
+	RegisterLauncher(&FakeLauncher{})
+}
+
+// runWorkDir 返回运行的工作目录
+func runWorkDir(id string) string {
+	return filepath.Join(DataDir, RunsDir, id)
+}
+
+// writeHostfile 在工作目录下写入 hostfile，每行一个节点
+func writeHostfile(workDir string, hosts []string) (string, error) {
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create run directory: %v", err)
+	}
+
+	hostfile := filepath.Join(workDir, "hostfile")
+	content := strings.Join(hosts, "\n") + "\n"
+	if err := os.WriteFile(hostfile, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write hostfile: %v", err)
+	}
+	return hostfile, nil
+}
+
+// ncclEnv 返回传递给所有 rank 的环境变量（KEY=VALUE）
+func ncclEnv(params NCCLTestParams) []string {
+	env := []string{"UCX_TLS=tcp"}
+
+	if params.EnableDebug && params.NCCLDebugLevel != "" {
+		// 启用 DEBUG 时使用指定的级别
+		env = append(env, "NCCL_DEBUG="+params.NCCLDebugLevel)
+	} else {
+		// 未启用 DEBUG 时设置为 VERSION，抑制 INFO 级别日志
+		env = append(env, "NCCL_DEBUG=VERSION")
+	}
+
+	env = append(env,
+		"NCCL_IB_GID_INDEX="+strconv.Itoa(params.NCCLIBGIDIndex),
+		"NCCL_MIN_NCHANNELS="+strconv.Itoa(params.NCCLMinChannels),
+		"NCCL_IB_QPS_PER_CONNECTION="+strconv.Itoa(params.NCCLIBQPSPerConnection),
+	)
+
+	// 用户指定的额外环境变量，按名称排序保证命令稳定
+	return append(env, sortedEnv(params.Env)...)
+}
+
+// ncclTestArgs 返回 nccl-tests 的命令行参数
+func ncclTestArgs(params NCCLTestParams) []string {
+	var args []string
+
+	// 只在有测试大小参数时才添加 -b 和 -e
+	if s := sizeArg(params.TestSizeBegin); s != "" {
+		args = append(args, "-b", s)
+	}
+	if s := sizeArg(params.TestSizeEnd); s != "" {
+		args = append(args, "-e", s)
+	}
+
+	if params.StepFactor > 0 {
+		args = append(args, "-f", strconv.Itoa(params.StepFactor))
+	}
+	if s := sizeArg(params.StepBytes); s != "" {
+		args = append(args, "-i", s)
+	}
+
+	// 只在有迭代次数参数时才添加 -n
+	if params.Iters > 0 {
+		args = append(args, "-n", strconv.Itoa(params.Iters))
+	}
+
+	if params.WarmupIters != nil {
+		args = append(args, "-w", strconv.Itoa(*params.WarmupIters))
+	}
+	if params.Check != nil {
+		args = append(args, "-c", strconv.Itoa(*params.Check))
+	}
+	if params.GPUsPerThread > 0 {
+		args = append(args, "-g", strconv.Itoa(params.GPUsPerThread))
+	}
+	if params.Blocking != nil {
+		args = append(args, "-z", strconv.Itoa(*params.Blocking))
+	}
+	if params.CUDAGraph != nil {
+		args = append(args, "-G", strconv.Itoa(*params.CUDAGraph))
+	}
+	if params.Datatype != "" {
+		args = append(args, "-d", params.Datatype)
+	}
+	if params.Op != "" {
+		args = append(args, "-o", params.Op)
+	}
+
+	return args
+}
+
+// ncclTestBinary 返回要执行的 nccl-tests 程序：指定集合通信类型时使用对应的 <collective>_perf
+func ncclTestBinary(params NCCLTestParams) string {
+	if params.Collective == "" {
+		return NCCLTestPath
+	}
+	return filepath.Join(NCCLTestsDir, params.Collective+"_perf")
+}
+
+// sizeArg 将 int 或 string 形式的大小参数转换为命令行文本，空值返回空字符串
+func sizeArg(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case float64:
+		// JSON 数字解码为 float64，避免输出科学计数法
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	default:
+		return fmt.Sprint(s)
+	}
+}
+
+// parseSize 解析 nccl-tests 的大小参数，支持 K/M/G 后缀（1024 进制），如 8K、128M、1G
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	multiplier := int64(1)
+	switch s[len(s)-1] {
+	case 'K', 'k':
+		multiplier = 1 << 10
+	case 'M', 'm':
+		multiplier = 1 << 20
+	case 'G', 'g':
+		multiplier = 1 << 30
+	}
+	if multiplier != 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return n * multiplier, nil
+}
+
+// shellSafePattern 无需引号即可在 shell 中原样使用的参数
+var shellSafePattern = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./^-]+$`)
+
+// shellQuote 将参数转换为 shell 中等价的文本，必要时使用单引号
+func shellQuote(arg string) string {
+	if shellSafePattern.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// renderCommand 将分组的参数渲染为可直接在 shell 中执行的命令文本，每组一行
+func renderCommand(groups [][]string) string {
+	lines := make([]string, 0, len(groups))
+	for _, group := range groups {
+		quoted := make([]string, 0, len(group))
+		for _, arg := range group {
+			quoted = append(quoted, shellQuote(arg))
+		}
+		lines = append(lines, strings.Join(quoted, " "))
+	}
+	return strings.Join(lines, " \\\n    ")
+}
+
+// flattenArgs 将分组的参数展开为 argv
+func flattenArgs(groups [][]string) []string {
+	var argv []string
+	for _, group := range groups {
+		argv = append(argv, group...)
+	}
+	return argv
+}
+
+// pprPattern 匹配 map_by 中的每节点进程数，如 ppr:8:node
+var pprPattern = regexp.MustCompile(`^ppr:(\d+):node$`)
+
+// procsPerNode 从 map_by 中解析每节点进程数，无法解析时返回 0
+func procsPerNode(mapBy string) int {
+	m := pprPattern.FindStringSubmatch(mapBy)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// FakeOutputFile 本地模拟启动器回放的输出文件，位于 DataDir 下，不存在时自动生成模拟输出
+	FakeOutputFile = "fake_output.txt"
+	// FakeHostsFile 模拟启动器的节点带宽系数，位于 DataDir 下，如 {"node03": 0.5}
+	// 运行包含系数小于 1 的节点时，整体带宽按最小的系数下降，用于在没有 GPU 的环境下模拟慢节点
+	FakeHostsFile = "fake_hosts.json"
+	// FakeLineDelay 模拟输出每行之间的间隔（秒）
+	FakeLineDelay = "0.05"
+
+	// 模拟输出使用的参数
+	fakeProcsPerNode = 8
+	fakeBusbwPeak    = 360.0 // 峰值总线带宽（GB/s）
+	fakeLatencyUs    = 20.0  // 基础延迟（us）
+	fakeGPUModel     = "NVIDIA H200"
+	fakeNCCLVersion  = "2.27.7+cuda12.4"
+)
+
+// fakeBusIDs 模拟输出中各 GPU 的 PCI 总线 ID
+var fakeBusIDs = []string{"0x18", "0x2a", "0x3a", "0x5d", "0x9a", "0xab", "0xba", "0xdb"}
+
+// FakeLauncher 本地模拟启动器，不需要 GPU 和 MPI，逐行回放 nccl-tests 风格的输出
+// 用于在没有 GPU 的环境下验证完整的运行流程
+type FakeLauncher struct{}
+
+// Name 启动器名称
+func (l *FakeLauncher) Name() string {
+	return LauncherFake
+}
+
+// Prepare 写入模拟输出文件，并生成逐行回放的进程
+func (l *FakeLauncher) Prepare(params NCCLTestParams, hosts []string, workDir string) (*LaunchSpec, error) {
+	output, err := os.ReadFile(filepath.Join(DataDir, FakeOutputFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read fake output: %v", err)
+		}
+		scale, err := fakeHostScale(hosts)
+		if err != nil {
+			return nil, err
+		}
+		output = []byte(fakeNCCLOutput(params, hosts, scale))
+	}
+
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create run directory: %v", err)
+	}
+	outputFile := filepath.Join(workDir, "fake_output.txt")
+	if err := os.WriteFile(outputFile, output, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write fake output: %v", err)
+	}
+
+	groups := [][]string{
+		{"sh", "-c", `while IFS= read -r line; do printf '%s\n' "$line"; sleep "$1"; done < "$2"`},
+		{"fake-nccl-test", FakeLineDelay, outputFile},
+	}
+	return &LaunchSpec{
+		Argv:    flattenArgs(groups),
+		Command: renderCommand(groups),
+	}, nil
+}
+
+// fakeHostScale 返回参与运行的节点中最小的带宽系数，没有配置时为 1
+func fakeHostScale(hosts []string) (float64, error) {
+	data, err := os.ReadFile(filepath.Join(DataDir, FakeHostsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 1, nil
+		}
+		return 0, fmt.Errorf("failed to read fake hosts: %v", err)
+	}
+
+	var factors map[string]float64
+	if err := json.Unmarshal(data, &factors); err != nil {
+		return 0, fmt.Errorf("failed to parse fake hosts: %v", err)
+	}
+	scale := 1.0
+	for _, host := range hostNames(hosts) {
+		if factor, ok := factors[host]; ok && factor > 0 && factor < scale {
+			scale = factor
+		}
+	}
+	return scale, nil
+}
+
+// fakeNCCLOutput 根据测试参数生成 nccl-tests 风格的模拟输出，scale 为带宽系数
+func fakeNCCLOutput(params NCCLTestParams, hosts []string, scale float64) string {
+	ppn := procsPerNode(params.MapBy)
+	if ppn <= 0 {
+		ppn = fakeProcsPerNode
+	}
+	worldSize := ppn * len(hosts)
+
+	minBytes, err := parseSize(sizeArg(params.TestSizeBegin))
+	if err != nil || minBytes <= 0 {
+		minBytes = 32 << 20
+	}
+	maxBytes, err := parseSize(sizeArg(params.TestSizeEnd))
+	if err != nil || maxBytes < minBytes {
+		maxBytes = minBytes
+	}
+	iters := params.Iters
+	if iters <= 0 {
+		iters = 20
+	}
+	collective := params.Collective
+	if collective == "" {
+		collective = "all_reduce"
+	}
+	datatype := params.Datatype
+	if datatype == "" || datatype == "all" {
+		datatype = "float"
+	}
+	op := params.Op
+	if op == "" || op == "all" {
+		op = "sum"
+	}
+	warmup := 5
+	if params.WarmupIters != nil {
+		warmup = *params.WarmupIters
+	}
+	check := 1
+	if params.Check != nil {
+		check = *params.Check
+	}
+	graph := 0
+	if params.CUDAGraph != nil {
+		graph = *params.CUDAGraph
+	}
+
+	// 步进方式：固定增量或倍增
+	stepBytes, _ := parseSize(sizeArg(params.StepBytes))
+	stepFactor := int64(params.StepFactor)
+	if stepFactor <= 1 {
+		stepFactor = 2
+	}
+	step := fmt.Sprintf("%d(factor)", stepFactor)
+	if stepBytes > 0 {
+		step = fmt.Sprintf("%d(bytes)", stepBytes)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] running nccl test %s %s, world_size=%d\n",
+		hostNames(hosts)[0], collective, strings.Join(ncclTestArgs(params), " "), worldSize)
+	fmt.Fprintf(&b, "# nGpus(perProc) 1 minBytes %d maxBytes %d step: %s warmup iters: %d iters: %d agg iters: 1 validation: %d graph: %d\n",
+		minBytes, maxBytes, step, warmup, iters, check, graph)
+	b.WriteString("#\n# Using devices\n")
+	for rank := 0; rank < worldSize; rank++ {
+		device := rank % ppn
+		fmt.Fprintf(&b, "#  Rank %2d Group  0 Pid %7d on %s device %2d [%s] %s\n",
+			rank, 100000+rank, hostNames(hosts)[rank/ppn], device, fakeBusIDs[device%len(fakeBusIDs)], fakeGPUModel)
+	}
+	fmt.Fprintf(&b, "NCCL version %s\n#\n", fakeNCCLVersion)
+	b.WriteString("#                                                              out-of-place                       in-place          \n")
+	b.WriteString("#       size         count      type   redop    root     time   algbw   busbw #wrong     time   algbw   busbw #wrong\n")
+	b.WriteString("#        (B)    (elements)                               (us)  (GB/s)  (GB/s)            (us)  (GB/s)  (GB/s)       \n")
+
+	factor := fakeBusbwFactor(collective, worldSize)
+	redop, root := op, -1
+	switch collective {
+	case "all_gather", "alltoall", "sendrecv", "broadcast":
+		redop = "none"
+	}
+	if collective == "broadcast" || collective == "reduce" {
+		root = 0
+	}
+	typeSize := fakeTypeSize(datatype)
+
+	var busbwSum float64
+	rows := 0
+	for size := minBytes; size <= maxBytes; {
+		// 简单的延迟 + 带宽模型
+		timeUs := fakeLatencyUs + float64(size)/(fakeBusbwPeak*scale/factor*1e3)
+		algbw := float64(size) / timeUs / 1e3
+		busbw := algbw * factor
+
+		row := fmt.Sprintf("%12d  %12d  %8s  %6s  %6d", size, size/typeSize, datatype, redop, root)
+		for i := 0; i < 2; i++ {
+			row += fmt.Sprintf("  %s  %6.2f  %6.2f  %5d", fakeTime(timeUs), algbw, busbw, 0)
+		}
+		b.WriteString(row + "\n")
+
+		busbwSum += 2 * busbw
+		rows += 2
+
+		if stepBytes > 0 {
+			size += stepBytes
+		} else if size == 0 {
+			size = 1
+		} else {
+			size *= stepFactor
+		}
+	}
+
+	if rows == 0 {
+		rows = 1
+	}
+	b.WriteString("# Out of bounds values : 0 OK\n")
+	fmt.Fprintf(&b, "# Avg bus bandwidth    : %.6g \n#\n", busbwSum/float64(rows))
+	return b.String()
+}
+
+// fakeBusbwFactor 返回 nccl-tests 中各集合通信的总线带宽系数
+func fakeBusbwFactor(collective string, n int) float64 {
+	if n <= 1 {
+		return 1
+	}
+	switch collective {
+	case "all_reduce":
+		return 2 * float64(n-1) / float64(n)
+	case "all_gather", "reduce_scatter", "alltoall":
+		return float64(n-1) / float64(n)
+	}
+	return 1
+}
+
+// fakeTypeSize 返回数据类型的字节数
+func fakeTypeSize(datatype string) int64 {
+	switch datatype {
+	case "int8", "char", "uint8", "f8e4m3", "f8e5m2":
+		return 1
+	case "half", "float16", "bfloat16":
+		return 2
+	case "int64", "uint64", "double", "float64":
+		return 8
+	}
+	return 4
+}
+
+// fakeTime 按 nccl-tests 的格式输出时间：较大的值不保留小数
+func fakeTime(us float64) string {
+	if us >= 10000 {
+		return fmt.Sprintf("%7.0f", us)
+	}
+	return fmt.Sprintf("%7.1f", us)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+// MPIRunLauncher 使用 Open MPI mpirun 启动测试
+type MPIRunLauncher struct{}
+
+// Name 启动器名称
+func (l *MPIRunLauncher) Name() string {
+	return LauncherMPIRun
+}
+
+// Prepare 写入 hostfile 并生成 mpirun 命令
+func (l *MPIRunLauncher) Prepare(params NCCLTestParams, hosts []string, workDir string) (*LaunchSpec, error) {
+	hostfile, err := writeHostfile(workDir, hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := buildNCCLCommand(params, hostfile)
+	return &LaunchSpec{
+		Argv:    flattenArgs(groups),
+		Command: renderCommand(groups),
+	}, nil
+}
+
+// buildNCCLCommand 构建 NCCL 测试命令，按命令行选项分组返回，不经过 shell
+func buildNCCLCommand(params NCCLTestParams, hostfile string) [][]string {
+	// 基础命令
+	groups := [][]string{
+		{MPIRunPath},
+		{"--allow-run-as-root"},
+		{"--hostfile", hostfile},
+		{"--map-by", params.MapBy},
+		{"--mca", "oob_tcp_if_include", params.OOBTCPInterface},
+		{"--mca", "pml", "^ucx"},
+		{"--mca", "btl", "self,tcp"},
+		{"--mca", "btl_tcp_if_include", params.BTLTCPInterface},
+		{"--mca", "routed", "direct"},
+		{"--mca", "plm_rsh_no_tree_spawn", "1"},
+	}
+
+	// 传递 NCCL 相关环境变量
+	for _, kv := range ncclEnv(params) {
+		groups = append(groups, []string{"-x", kv})
+	}
+
+	// 添加测试命令
+	return append(groups, append([]string{ncclTestBinary(params)}, ncclTestArgs(params)...))
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SlurmLauncher 使用 Slurm 启动测试
+// UseSalloc 为 true 时先通过 salloc 申请节点再在分配内执行 srun，否则直接使用 srun
+// IP 列表直接作为 --nodelist 传给 Slurm，必须是 Slurm 节点名（sinfo -N 中的 NODELIST），不能是 IP 地址
+type SlurmLauncher struct {
+	UseSalloc bool
+}
+
+// Name 启动器名称
+func (l *SlurmLauncher) Name() string {
+	if l.UseSalloc {
+		return LauncherSalloc
+	}
+	return LauncherSrun
+}
+
+// Prepare 生成 srun/salloc 命令，NCCL 环境变量通过进程环境传递（srun 默认 --export=ALL）
+func (l *SlurmLauncher) Prepare(params NCCLTestParams, hosts []string, workDir string) (*LaunchSpec, error) {
+	names := hostNames(hosts)
+	if err := checkSlurmNodeNames(names); err != nil {
+		return nil, err
+	}
+	nodeGroups := [][]string{
+		{"--nodes=" + strconv.Itoa(len(hosts))},
+		{"--nodelist=" + strings.Join(names, ",")},
+	}
+
+	var groups [][]string
+	if l.UseSalloc {
+		groups = append([][]string{{SallocPath}}, nodeGroups...)
+		groups = append(groups, []string{SrunPath})
+	} else {
+		groups = append([][]string{{SrunPath}}, nodeGroups...)
+	}
+
+	if n := procsPerNode(params.MapBy); n > 0 {
+		groups = append(groups, []string{"--ntasks-per-node=" + strconv.Itoa(n)})
+	}
+
+	groups = append(groups, append([]string{ncclTestBinary(params)}, ncclTestArgs(params)...))
+
+	// 展示的命令中以 env 前缀体现传递的环境变量
+	env := ncclEnv(params)
+	display := append([][]string{append([]string{"env"}, env...)}, groups...)
+
+	return &LaunchSpec{
+		Argv:    flattenArgs(groups),
+		Env:     env,
+		Command: renderCommand(display),
+	}, nil
+}
+
+// checkSlurmNodeNames 检查 IP 列表中的节点是否为 Slurm 节点名，Slurm 不接受 IP 地址作为 --nodelist
+func checkSlurmNodeNames(names []string) error {
+	var ips []string
+	for _, name := range names {
+		if net.ParseIP(name) != nil {
+			ips = append(ips, name)
+		}
+	}
+	if len(ips) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: map[string]string{
+		"iplist_file": fmt.Sprintf("the Slurm launchers need Slurm node names in the iplist, not IP addresses: %s", strings.Join(ips, ", ")),
+	}}
+}
+
+// hostNames 提取 hostfile 条目中的节点名（去掉 slots=N 等附加字段）
+func hostNames(hosts []string) []string {
+	names := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if fields := strings.Fields(host); len(fields) > 0 {
+			names = append(names, fields[0])
+		}
+	}
+	return names
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// historyLogsExt 解析后的 NCCL 日志文件后缀：<id>.logs.json，没有 NCCL 日志时不生成
+const historyLogsExt = ".logs.json"
+
+const (
+	// DefaultLogLimit 日志查询默认返回的条数
+	DefaultLogLimit = 1000
+	// MaxLogLimit 日志查询最多返回的条数
+	MaxLogLimit = 10000
+)
+
+// ncclLogPattern 匹配 NCCL 调试日志，如
+//
+//	cetus-g88-061:3259226:3260615 [7] NCCL INFO Connected all trees
+//	cetus-g88-061:3259226:3260615 [7] 1234.567890 ncclProxyProgress:512 NCCL TRACE ...
+//
+// 依次为 host、pid、tid、device、TRACE 的时间戳和源码位置（可选）、级别、内容
+var ncclLogPattern = regexp.MustCompile(`^\s*([^\s:]+):(\d+):(\d+) \[(\d+)\] (?:([\d.]+) )?(?:(\S+:\d+) )?NCCL (INFO|WARN|TRACE|ABORT|VERSION)\b ?(.*)$`)
+
+// ncclSubsystems 根据日志内容猜测 NCCL_DEBUG_SUBSYS 子系统，按顺序匹配第一个规则
+// NCCL 不在日志行中打印子系统，这里只按常见的消息前缀和关键字尽力归类，结果可能不准确，
+// 无法归类时为空；按子系统过滤会漏掉未归类或归错类的日志
+var ncclSubsystems = []struct {
+	Subsystem string
+	Keywords  []string
+}{
+	{"NET", []string{"NET/", "NCCL_IB_", "NCCL_NET", "NCCL_SOCKET", "GPU Direct RDMA", "GDRDMA", "IB Device", "Using network"}},
+	{"BOOTSTRAP", []string{"Bootstrap", "bootstrap"}},
+	{"ENV", []string{"set by environment", "NCCL_DEBUG", "environment to"}},
+	{"TUNING", []string{"Tuner", "tuner", "Tuning", "Algorithm", "protocol"}},
+	{"NVLS", []string{"NVLS", "NVLink SHARP"}},
+	{"P2P", []string{"via P2P", "P2P/", "P2P is", "Channel "}},
+	{"SHM", []string{"via SHM", "SHM/"}},
+	{"PROXY", []string{"Proxy", "proxy"}},
+	{"INIT", []string{"Connected all", "comm 0x", "Init", "NCCL version", "cudaDriverVersion", "nranks", "Launch mode", "CC Off"}},
+	{"GRAPH", []string{"Trees", "Pattern", "Ring", "ring", "tree", "topology", "Topology", "graph", "NVLink", "PCI"}},
+	{"ALLOC", []string{"cudaMalloc", "Allocated", "alloc", "memory", "cuMem"}},
+	{"COLL", []string{"AllReduce", "AllGather", "ReduceScatter", "Broadcast", "SendRecv", "opCount"}},
+}
+
+// LogEntry 一条 NCCL 调试日志
+type LogEntry struct {
+	Line              int     `json:"line"` // 在运行输出中的行号，从 1 开始
+	Host              string  `json:"host"`
+	Pid               int     `json:"pid"`
+	Tid               int     `json:"tid"`
+	Device            int     `json:"device"`
+	Level             string  `json:"level"`                     // INFO, WARN, TRACE, ABORT, VERSION
+	InferredSubsystem string  `json:"inferred_subsys,omitempty"` // 根据内容猜测的子系统，如 NET, INIT, P2P，不保证准确
+	Time              float64 `json:"time,omitempty"`            // TRACE 日志的时间戳
+	Source            string  `json:"source,omitempty"`          // TRACE 日志的源码位置，如 ncclProxyProgress:512
+	Message           string  `json:"message"`
+}
+
+// LogStats 运行输出中 NCCL 日志的统计，用于找出异常的节点
+type LogStats struct {
+	Entries int                       `json:"entries"`
+	Levels  map[string]int            `json:"levels"`
+	Hosts   map[string]map[string]int `json:"hosts"` // 节点 -> 级别 -> 条数
+}
+
+// LogQuery 日志查询条件，为空的条件不过滤
+type LogQuery struct {
+	Hosts             []string // 节点名，多个用逗号分隔
+	Levels            []string // 级别，多个用逗号分隔
+	InferredSubsystem string   // 猜测的子系统，见 ncclSubsystems
+	Text              string   // 不区分大小写的子串匹配
+	Offset            int
+	Limit             int
+}
+
+// parseLogLine 解析一行 NCCL 调试日志，不是 NCCL 日志时返回 false
+func parseLogLine(line string) (LogEntry, bool) {
+	m := ncclLogPattern.FindStringSubmatch(line)
+	if m == nil {
+		return LogEntry{}, false
+	}
+
+	entry := LogEntry{
+		Host:    m[1],
+		Level:   m[7],
+		Source:  m[6],
+		Message: strings.TrimSpace(m[8]),
+	}
+	entry.Pid, _ = strconv.Atoi(m[2])
+	entry.Tid, _ = strconv.Atoi(m[3])
+	entry.Device, _ = strconv.Atoi(m[4])
+	if m[5] != "" {
+		entry.Time, _ = strconv.ParseFloat(m[5], 64)
+	}
+	entry.InferredSubsystem = ncclSubsystem(entry.Message)
+	return entry, true
+}
+
+// ncclSubsystem 根据日志内容猜测子系统，无法归类时返回空
+func ncclSubsystem(message string) string {
+	for _, rule := range ncclSubsystems {
+		for _, keyword := range rule.Keywords {
+			if strings.Contains(message, keyword) {
+				return rule.Subsystem
+			}
+		}
+	}
+	return ""
+}
+
+// matches 检查日志是否满足查询条件，text 需要预先转为小写
+func (q LogQuery) matches(entry LogEntry, text string) bool {
+	if len(q.Hosts) > 0 && !containsFold(q.Hosts, entry.Host) {
+		return false
+	}
+	if len(q.Levels) > 0 && !containsFold(q.Levels, entry.Level) {
+		return false
+	}
+	if q.InferredSubsystem != "" && !strings.EqualFold(q.InferredSubsystem, entry.InferredSubsystem) {
+		return false
+	}
+	return text == "" || strings.Contains(strings.ToLower(entry.Message), text)
+}
+
+// containsFold 不区分大小写检查字符串是否在列表中
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryLogs 解析输出中的 NCCL 日志，返回统计、满足条件的总数和分页后的日志
+func QueryLogs(lines []string, q LogQuery) (LogStats, int, []LogEntry) {
+	return queryLogEntries(parseLogEntries(lines), q)
+}
+
+// parseLogEntries 解析输出中的所有 NCCL 日志
+func parseLogEntries(lines []string) []LogEntry {
+	return appendLogEntries([]LogEntry{}, lines, 0)
+}
+
+// appendLogEntries 解析从第 start 行（从 0 开始）起的输出，追加到 entries
+func appendLogEntries(entries []LogEntry, lines []string, start int) []LogEntry {
+	for i, line := range lines {
+		entry, ok := parseLogLine(line)
+		if !ok {
+			continue
+		}
+		entry.Line = start + i + 1
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// queryLogEntries 统计已解析的日志，返回统计、满足条件的总数和分页后的日志
+func queryLogEntries(all []LogEntry, q LogQuery) (LogStats, int, []LogEntry) {
+	stats := LogStats{Levels: map[string]int{}, Hosts: map[string]map[string]int{}}
+	entries := []LogEntry{}
+	text := strings.ToLower(q.Text)
+	total := 0
+
+	for _, entry := range all {
+		stats.Entries++
+		stats.Levels[entry.Level]++
+		if stats.Hosts[entry.Host] == nil {
+			stats.Hosts[entry.Host] = map[string]int{}
+		}
+		stats.Hosts[entry.Host][entry.Level]++
+
+		if !q.matches(entry, text) {
+			continue
+		}
+		total++
+		if total > q.Offset && len(entries) < q.Limit {
+			entries = append(entries, entry)
+		}
+	}
+	return stats, total, entries
+}
+
+// summarizeLogs 统计已解析的 NCCL 日志，没有 NCCL 日志（未开启 EnableDebug）时返回 nil
+func summarizeLogs(entries []LogEntry) *LogStats {
+	stats, _, _ := queryLogEntries(entries, LogQuery{})
+	if stats.Entries == 0 {
+		return nil
+	}
+	return &stats
+}
+
+// logIndex 内存中运行的 NCCL 日志，查询时只解析上次查询之后新增的行
+type logIndex struct {
+	mu      sync.Mutex
+	parsed  int // 已解析的行数
+	entries []LogEntry
+}
+
+// update 解析新增的输出，返回目前的全部日志和输出是否已结束
+func (x *logIndex) update(log *RunLog) ([]LogEntry, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	lines, next, _, closed := log.Since(x.parsed)
+	x.entries = appendLogEntries(x.entries, lines, x.parsed)
+	x.parsed = next
+	return x.entries[:len(x.entries):len(x.entries)], closed
+}
+
+// writeHistoryLogs 保存解析后的 NCCL 日志，之后的查询不再解析输出
+func writeHistoryLogs(id string, entries []LogEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode logs: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(HistoryDir, id+historyLogsExt), data, 0644); err != nil {
+		return fmt.Errorf("failed to write logs: %v", err)
+	}
+	return nil
+}
+
+// parseLogQuery 从查询参数 host, level, inferred_subsys, q, offset, limit 解析查询条件
+func parseLogQuery(c *gin.Context) (LogQuery, error) {
+	q := LogQuery{
+		Hosts:             splitList(c.Query("host")),
+		Levels:            splitList(c.Query("level")),
+		InferredSubsystem: c.Query("inferred_subsys"),
+		Text:              c.Query("q"),
+		Limit:             DefaultLogLimit,
+	}
+	for _, level := range q.Levels {
+		switch strings.ToUpper(level) {
+		case "INFO", "WARN", "TRACE", "ABORT", "VERSION":
+		default:
+			return q, fmt.Errorf("invalid level: %s", level)
+		}
+	}
+	if s := c.Query("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("invalid offset: %s", s)
+		}
+		q.Offset = n
+	}
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > MaxLogLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", MaxLogLimit)
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+// splitList 拆分逗号分隔的查询参数，忽略空项
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// runLogEntries 获取运行的 NCCL 日志：内存中的运行增量解析日志，已淘汰的运行读取历史记录保存的解析结果
+// 没有解析结果的旧版记录从输出重新解析，开头的命令行会被去掉，使行号与运行日志一致
+func runLogEntries(id string) ([]LogEntry, bool, error) {
+	if run, ok := defaultRunManager.Get(id); ok {
+		entries, closed := run.logs.update(run.Log())
+		return entries, closed, nil
+	}
+
+	id, ok := historyID(id)
+	if !ok {
+		return nil, false, os.ErrNotExist
+	}
+	data, err := os.ReadFile(filepath.Join(HistoryDir, id+historyLogsExt))
+	if err == nil {
+		var entries []LogEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, false, fmt.Errorf("failed to parse logs: %v", err)
+		}
+		return entries, true, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, false, err
+	}
+
+	meta, err := loadHistoryMeta(id)
+	if err != nil {
+		return nil, false, err
+	}
+	if meta != nil && meta.Summary != nil && meta.Logs == nil {
+		// 保存时已解析过且没有 NCCL 日志
+		if _, err := os.Stat(filepath.Join(HistoryDir, id+historyOutputExt)); err != nil {
+			return nil, false, err
+		}
+		return []LogEntry{}, true, nil
+	}
+	data, err = os.ReadFile(filepath.Join(HistoryDir, id+historyOutputExt))
+	if err != nil {
+		return nil, false, err
+	}
+	output := string(data)
+	if meta != nil {
+		output = strings.TrimPrefix(output, meta.Command+"\n\n")
+	}
+	return parseLogEntries(strings.Split(output, "\n")), true, nil
+}
+
+// GetRunLogs 查询运行输出中的 NCCL 调试日志，支持按节点、级别、子系统和内容过滤
+// 例如 /runs/:id/logs?host=cetus-g88-061&level=WARN&q=timeout
+// 日志解析一次后保存（内存中的运行在查询时增量解析，历史记录保存为 <id>.logs.json），查询不重复解析输出
+func GetRunLogs(c *gin.Context) {
+	q, err := parseLogQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := c.Param("id")
+	all, finished, err := runLogEntries(id)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Run not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to read run output: %v", err),
+		})
+		return
+	}
+
+	stats, total, entries := queryLogEntries(all, q)
+
+	hosts := make([]string, 0, len(stats.Hosts))
+	for host := range stats.Hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":       id,
+		"finished": finished,
+		"total":    total,
+		"offset":   q.Offset,
+		"limit":    q.Limit,
+		"entries":  entries,
+		"hosts":    hosts,
+		"stats":    stats,
+	})
+}
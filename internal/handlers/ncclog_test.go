@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLogLine(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		line  string
+		ok    bool
+		entry LogEntry
+	}{
+		{
+			desc: "INFO",
+			line: "cetus-g88-061:3259226:3260615 [7] NCCL INFO Connected all trees",
+			ok:   true,
+			entry: LogEntry{Host: "cetus-g88-061", Pid: 3259226, Tid: 3260615, Device: 7,
+				Level: "INFO", InferredSubsystem: "INIT", Message: "Connected all trees"},
+		},
+		{
+			desc: "WARN",
+			line: "node02:1201:1288 [3] NCCL WARN NET/IB : Got completion from peer 10.0.0.1<33642> with error 12",
+			ok:   true,
+			entry: LogEntry{Host: "node02", Pid: 1201, Tid: 1288, Device: 3,
+				Level: "WARN", InferredSubsystem: "NET", Message: "NET/IB : Got completion from peer 10.0.0.1<33642> with error 12"},
+		},
+		{
+			desc: "TRACE 带时间戳和源码位置",
+			line: "node01:100:101 [0] 1523.402114 ncclProxyProgress:512 NCCL TRACE Received data",
+			ok:   true,
+			entry: LogEntry{Host: "node01", Pid: 100, Tid: 101, Device: 0, Level: "TRACE",
+				Time: 1523.402114, Source: "ncclProxyProgress:512", Message: "Received data"},
+		},
+		{
+			desc: "环境变量",
+			line: "node01:100:100 [0] NCCL INFO NCCL_IB_GID_INDEX set by environment to 3.",
+			ok:   true,
+			entry: LogEntry{Host: "node01", Pid: 100, Tid: 100, Level: "INFO",
+				InferredSubsystem: "NET", Message: "NCCL_IB_GID_INDEX set by environment to 3."},
+		},
+		{
+			desc: "数据行",
+			line: "     1024           256     float     sum      -1    18.52    0.06    0.10      0",
+		},
+		{
+			desc: "设备列表",
+			line: "#  Rank  0 Group  0 Pid 3259226 on cetus-g88-061 device  0 [0x18] NVIDIA H200",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			entry, ok := parseLogLine(tc.line)
+			if ok != tc.ok {
+				t.Fatalf("ok = %v, want %v", ok, tc.ok)
+			}
+			if !reflect.DeepEqual(entry, tc.entry) {
+				t.Errorf("entry = %+v, want %+v", entry, tc.entry)
+			}
+		})
+	}
+}
+
+func TestQueryLogs(t *testing.T) {
+	lines := []string{
+		"# nThread 1 nGpus 1 minBytes 1024 maxBytes 1073741824 step: 2(factor) warmup iters: 5 iters: 20",
+		"node01:100:100 [0] NCCL INFO Bootstrap : Using bond0:10.0.0.1<0>",
+		"node02:200:200 [0] NCCL INFO Bootstrap : Using bond0:10.0.0.2<0>",
+		"node02:200:210 [0] NCCL WARN NET/IB : mlx5_0:1 Got async event : port error",
+		"node02:200:210 [1] NCCL WARN Call to ibv_modify_qp failed with error Connection timed out",
+		"node01:100:110 [0] NCCL WARN Timeout waiting for proxy",
+		"node01:100:100 [0] NCCL INFO Connected all rings",
+	}
+
+	testCases := []struct {
+		desc  string
+		query LogQuery
+		total int
+		lines []int // 返回的日志行号
+	}{
+		{
+			desc:  "不过滤",
+			query: LogQuery{Limit: 10},
+			total: 6,
+			lines: []int{2, 3, 4, 5, 6, 7},
+		},
+		{
+			desc:  "一个节点的 WARN",
+			query: LogQuery{Hosts: []string{"node02"}, Levels: []string{"warn"}, Limit: 10},
+			total: 2,
+			lines: []int{4, 5},
+		},
+		{
+			desc:  "内容不区分大小写",
+			query: LogQuery{Text: "TIMED OUT", Limit: 10},
+			total: 1,
+			lines: []int{5},
+		},
+		{
+			desc:  "子系统",
+			query: LogQuery{InferredSubsystem: "bootstrap", Limit: 10},
+			total: 2,
+			lines: []int{2, 3},
+		},
+		{
+			desc:  "分页",
+			query: LogQuery{Levels: []string{"WARN"}, Offset: 1, Limit: 1},
+			total: 3,
+			lines: []int{5},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			stats, total, entries := QueryLogs(lines, tc.query)
+			got := []int{}
+			for _, entry := range entries {
+				got = append(got, entry.Line)
+			}
+			if total != tc.total || !reflect.DeepEqual(got, tc.lines) {
+				t.Errorf("total = %d, lines = %v, want %d, %v", total, got, tc.total, tc.lines)
+			}
+			if stats.Entries != 6 || stats.Levels["WARN"] != 3 || stats.Hosts["node02"]["WARN"] != 2 {
+				t.Errorf("unexpected stats: %+v", stats)
+			}
+		})
+	}
+}
+
+func TestLogIndex(t *testing.T) {
+	log := NewRunLog()
+	var x logIndex
+
+	log.Append("node01:100:100 [0] NCCL INFO Bootstrap : Using bond0:10.0.0.1<0>")
+	log.Append("# Out of bounds values : 0 OK")
+	if entries, closed := x.update(log); len(entries) != 1 || closed {
+		t.Fatalf("entries = %+v, closed = %v", entries, closed)
+	}
+
+	// 只解析新增的行，行号接着之前的输出
+	log.Append("node02:200:210 [1] NCCL WARN Call to ibv_modify_qp failed")
+	log.Close()
+	entries, closed := x.update(log)
+	if len(entries) != 2 || entries[1].Line != 3 || entries[1].Host != "node02" || !closed {
+		t.Errorf("entries = %+v, closed = %v", entries, closed)
+	}
+	if x.parsed != 3 {
+		t.Errorf("parsed = %d, want 3", x.parsed)
+	}
+}
@@ -14,9 +14,22 @@ const (
 	tableHeaderPattern = `^\s*#.*size.*count.*type.*time.*algbw.*busbw`
 	// 匹配表格结束标记
 	tableEndPattern = `^\s*#\s*(Out of bounds|Avg bus bandwidth)`
-	// 匹配数据行：数字开头，后面跟着至少11个空白分隔的字段（总共12个字段）
-	// 格式：size count type redop root time algbw busbw #wrong time algbw busbw #wrong
-	dataLinePattern = `^\s*\d+\s+\d+\s+\S+\s+\S+\s+\S+\s+[\d.]+\s+[\d.]+\s+[\d.]+\s+\d+\s+[\d.]+\s+[\d.]+\s+[\d.]+`
+	// 匹配数据行：以整数（size 字段）开头，各列按表头的列名解析
+	dataLinePattern = `^\s*\d+\s`
+	// 匹配测试参数行，如 # nGpus(perProc) 1 minBytes 1 maxBytes 1073741824 step: 2(factor) warmup iters: 5 iters: 20 agg iters: 1 validation: 1 graph: 0
+	// 旧版本格式为 # nThread 1 nGpus 1 minBytes ...
+	runHeaderPattern = `^\s*#\s*(?:nThread\s+(\d+)\s+)?nGpus(?:\(perProc\))?\s+(\d+)\s+minBytes\s+(\d+)\s+maxBytes\s+(\d+)\s+step:\s*(\S+)\s+warmup iters:\s*(\d+)\s+iters:\s*(\d+)(?:\s+agg iters:\s*(\d+))?(?:\s+validation:\s*(\d+))?(?:\s+graph:\s*(\d+))?`
+	// 匹配 nccl_test 启动脚本打印的运行信息，如 [cetus-g88-094] running nccl test all_reduce -b 1 -e 1G, world_size=16
+	runningTestPattern = `running nccl test\s+(\w+)(?:.*world_size=(\d+))?`
+	// 匹配较新版本 nccl-tests 打印的测试名称，如 # Collective test starting: all_reduce_perf
+	collectiveStartPattern = `^\s*#\s*Collective test starting:\s*(\w+?)(?:_perf)?\s*$`
+	// 匹配 NCCL 版本，如 NCCL version 2.27.7+cuda12.4，也可能出现在 NCCL INFO 日志中
+	ncclVersionPattern = `NCCL version\s+(\d[\w.-]*?)(?:\+cuda([\d.]+))?(?:\s|$)`
+	// 匹配设备列表开始标记
+	devicesStartPattern = `^\s*#\s*Using devices`
+	// 匹配设备行，如 #  Rank  7 Group  0 Pid 2562583 on cetus-g88-094 device  7 [0xd7] NVIDIA H200
+	// 旧版本没有 Group 字段
+	deviceLinePattern = `^\s*#\s*Rank\s+(\d+)\s+(?:Group\s+(\d+)\s+)?Pid\s+(\d+)\s+on\s+(\S+)\s+device\s+(\d+)\s+\[([^\]]*)\]\s*(.*?)\s*$`
 )
 
 // ChartDataPoint 表示图表的一个数据点
@@ -24,27 +37,88 @@ type ChartDataPoint struct {
 	Size     int     `json:"size"`
 	Count    int     `json:"count"`
 	Type     string  `json:"type"`
+	OutTime  float64 `json:"outTime"` // us
 	OutAlgbw float64 `json:"outAlgbw"`
 	OutBusbw float64 `json:"outBusbw"`
+	OutWrong int     `json:"outWrong"` // #wrong，校验出错的元素数量，非 0 表示数据损坏
+	InTime   float64 `json:"inTime"`   // us
 	InAlgbw  float64 `json:"inAlgbw"`
 	InBusbw  float64 `json:"inBusbw"`
+	InWrong  int     `json:"inWrong"`
+}
+
+// RunHeader nccl-tests 输出开头的运行信息和测试参数
+type RunHeader struct {
+	Collective  string `json:"collective,omitempty"`   // 集合通信类型，如 all_reduce
+	WorldSize   int    `json:"world_size,omitempty"`   // rank 总数，启动脚本没有打印时取设备列表的行数
+	NCCLVersion string `json:"nccl_version,omitempty"` // 如 2.27.7
+	CUDAVersion string `json:"cuda_version,omitempty"` // NCCL 编译时的 CUDA 版本，如 12.4
+
+	NThreads    int    `json:"n_threads,omitempty"` // 旧版本输出中的线程数
+	NGpus       int    `json:"n_gpus"`              // 每个进程（或线程）的 GPU 数
+	MinBytes    int64  `json:"min_bytes"`
+	MaxBytes    int64  `json:"max_bytes"`
+	Step        string `json:"step"` // 如 2(factor) 或 1048576(bytes)
+	WarmupIters int    `json:"warmup_iters"`
+	Iters       int    `json:"iters"`
+	AggIters    int    `json:"agg_iters"`
+	Validation  int    `json:"validation"`
+	Graph       int    `json:"graph"`
+}
+
+// DeviceInfo 设备列表中的一个 rank
+type DeviceInfo struct {
+	Rank   int    `json:"rank"`
+	Group  int    `json:"group"`
+	Pid    int    `json:"pid"`
+	Host   string `json:"host"`
+	Device int    `json:"device"`
+	BusID  string `json:"bus_id"`
+	Model  string `json:"model"`
+}
+
+// 增量解析产生的事件类型，与 SSE 事件名相同
+const (
+	ParseEventHeader    = "header"    // 测试参数，遇到表头时发送，数据为 RunHeader
+	ParseEventDevices   = "devices"   // 设备列表，列表结束时发送，数据为 []DeviceInfo
+	ParseEventDataPoint = "datapoint" // 表格中的一行，数据为 ChartDataPoint
+	ParseEventSummary   = "summary"   // 表格结束时发送，数据为 ResultSummary
+	ParseEventUnparsed  = "unparsed"  // 表格中无法解析的数据行，数据为 UnparsedRow
+)
+
+// ParseEvent 增量解析产生的事件
+type ParseEvent struct {
+	Type string
+	Data interface{}
 }
 
 // NCCLOutputParser NCCL 输出解析器
 type NCCLOutputParser struct {
-	whitespaceRegex  *regexp.Regexp
-	tableHeaderRegex *regexp.Regexp
-	tableEndRegex    *regexp.Regexp
-	dataLineRegex    *regexp.Regexp
+	whitespaceRegex   *regexp.Regexp
+	tableHeaderRegex  *regexp.Regexp
+	tableEndRegex     *regexp.Regexp
+	dataLineRegex     *regexp.Regexp
+	runHeaderRegex    *regexp.Regexp
+	runningTestRegex  *regexp.Regexp
+	collectiveRegex   *regexp.Regexp
+	ncclVersionRegex  *regexp.Regexp
+	devicesStartRegex *regexp.Regexp
+	deviceLineRegex   *regexp.Regexp
 }
 
 // NewNCCLOutputParser 创建新的 NCCL 输出解析器
 func NewNCCLOutputParser() *NCCLOutputParser {
 	return &NCCLOutputParser{
-		whitespaceRegex:  regexp.MustCompile(whitespacePattern),
-		tableHeaderRegex: regexp.MustCompile(tableHeaderPattern),
-		tableEndRegex:    regexp.MustCompile(tableEndPattern),
-		dataLineRegex:    regexp.MustCompile(dataLinePattern),
+		whitespaceRegex:   regexp.MustCompile(whitespacePattern),
+		tableHeaderRegex:  regexp.MustCompile(tableHeaderPattern),
+		tableEndRegex:     regexp.MustCompile(tableEndPattern),
+		dataLineRegex:     regexp.MustCompile(dataLinePattern),
+		runHeaderRegex:    regexp.MustCompile(runHeaderPattern),
+		runningTestRegex:  regexp.MustCompile(runningTestPattern),
+		collectiveRegex:   regexp.MustCompile(collectiveStartPattern),
+		ncclVersionRegex:  regexp.MustCompile(ncclVersionPattern),
+		devicesStartRegex: regexp.MustCompile(devicesStartPattern),
+		deviceLineRegex:   regexp.MustCompile(deviceLinePattern),
 	}
 }
 
@@ -54,28 +128,198 @@ func (p *NCCLOutputParser) Parse(output string) []ChartDataPoint {
 		return []ChartDataPoint{}
 	}
 
-	lines := strings.Split(output, "\n")
-	data := []ChartDataPoint{}
-	parsing := false
+	stream := p.NewStream()
+	for _, line := range strings.Split(output, "\n") {
+		stream.Feed(line)
+	}
+	return stream.DataPoints()
+}
+
+// NCCLStreamParser 增量解析器：逐行输入输出，识别到测试参数、设备列表、数据行和汇总时产生事件
+// 每个解析器保存自己的状态，不能在多个输出流之间共享
+type NCCLStreamParser struct {
+	parser *NCCLOutputParser
+
+	header      *RunHeader
+	devices     []DeviceInfo
+	layout      *tableLayout
+	points      []ChartDataPoint
+	rawLines    []string
+	unparsed    []UnparsedRow
+	lines       int // 已输入的行数
+	summary     *ResultSummary
+	avgBusbw    *float64
+	outOfBounds *int
+	inDevices   bool
+	parsing     bool // 已遇到表头
+	ended       bool // 已遇到表格结束标记
+}
+
+// NewStream 创建增量解析器
+func (p *NCCLOutputParser) NewStream() *NCCLStreamParser {
+	return &NCCLStreamParser{
+		parser:   p,
+		points:   []ChartDataPoint{},
+		rawLines: []string{},
+		unparsed: []UnparsedRow{},
+	}
+}
+
+// NewNCCLStreamParser 创建增量解析器（便捷函数）
+func NewNCCLStreamParser() *NCCLStreamParser {
+	return NewNCCLOutputParser().NewStream()
+}
+
+// Feed 输入一行输出，返回该行产生的事件
+func (s *NCCLStreamParser) Feed(line string) []ParseEvent {
+	var events []ParseEvent
+	p := s.parser
+	s.lines++
 
-	for _, line := range lines {
-		if p.isTableHeader(line) {
-			parsing = true
-			continue
+	// 设备列表在第一个非设备行处结束
+	if s.inDevices {
+		if device, ok := p.parseDeviceLine(line); ok {
+			s.devices = append(s.devices, device)
+			return nil
 		}
+		s.inDevices = false
+		events = append(events, s.devicesEvent()...)
+	}
 
+	switch {
+	case s.ended:
+		// 表格之后只关心越界值数量和平均总线带宽
+		s.parseOutOfBounds(line)
+		if avg, ok := parseAvgBusbw(line); ok && s.summary == nil {
+			s.avgBusbw = &avg
+			events = append(events, s.summaryEvent())
+		}
+	case s.parsing:
 		if p.isTableEnd(line) {
+			s.ended = true
+			s.parseOutOfBounds(line)
+			if avg, ok := parseAvgBusbw(line); ok {
+				s.avgBusbw = &avg
+				events = append(events, s.summaryEvent())
+			}
 			break
 		}
-
-		if parsing {
-			if dataPoint, ok := p.parseDataLine(line); ok {
-				data = append(data, dataPoint)
+		if p.isDataLine(line) {
+			point, reason := s.layout.parseRow(p.splitFields(line))
+			if reason != "" {
+				row := UnparsedRow{Line: s.lines, Text: line, Reason: reason}
+				s.unparsed = append(s.unparsed, row)
+				events = append(events, ParseEvent{Type: ParseEventUnparsed, Data: row})
+				break
+			}
+			s.rawLines = append(s.rawLines, line)
+			// size 为 0 的行没有带宽数据，不作为数据点
+			if point.Size > 0 {
+				s.points = append(s.points, point)
+				events = append(events, ParseEvent{Type: ParseEventDataPoint, Data: point})
 			}
 		}
+	case p.isTableHeader(line):
+		s.parsing = true
+		s.layout = p.parseTableLayout(line)
+		if s.header != nil {
+			events = append(events, ParseEvent{Type: ParseEventHeader, Data: *s.header})
+		}
+	case p.devicesStartRegex.MatchString(line):
+		s.inDevices = true
+	default:
+		header := RunHeader{}
+		if s.header != nil {
+			header = *s.header
+		}
+		if p.parsePreamble(line, &header) {
+			s.header = &header
+		}
+	}
+
+	return events
+}
+
+// Finish 输出结束时调用，补发未结束的设备列表和汇总（例如运行被中途停止）
+func (s *NCCLStreamParser) Finish() []ParseEvent {
+	var events []ParseEvent
+	if s.inDevices {
+		s.inDevices = false
+		events = append(events, s.devicesEvent()...)
+	}
+	if s.parsing && s.summary == nil {
+		events = append(events, s.summaryEvent())
+	}
+	return events
+}
+
+// parseOutOfBounds 记录表格结束处报告的越界值数量
+func (s *NCCLStreamParser) parseOutOfBounds(line string) {
+	if n, ok := parseOutOfBounds(line); ok {
+		s.outOfBounds = &n
+	}
+}
+
+// devicesEvent 生成设备列表事件，列表为空时不产生事件
+// 启动脚本没有打印 world_size 时，以设备列表的行数（每个 rank 一行）作为 rank 总数
+func (s *NCCLStreamParser) devicesEvent() []ParseEvent {
+	if len(s.devices) == 0 {
+		return nil
+	}
+	if s.header == nil {
+		s.header = &RunHeader{}
 	}
+	if s.header.WorldSize == 0 {
+		s.header.WorldSize = len(s.devices)
+	}
+	return []ParseEvent{{Type: ParseEventDevices, Data: s.devices}}
+}
 
-	return data
+// summaryEvent 根据已解析的数据点生成汇总事件
+func (s *NCCLStreamParser) summaryEvent() ParseEvent {
+	summary := s.Summary()
+	s.summary = &summary
+	return ParseEvent{Type: ParseEventSummary, Data: summary}
+}
+
+// Header 返回已解析的测试参数，尚未出现时返回 nil
+func (s *NCCLStreamParser) Header() *RunHeader {
+	return s.header
+}
+
+// Devices 返回已解析的设备列表
+func (s *NCCLStreamParser) Devices() []DeviceInfo {
+	if s.devices == nil {
+		return []DeviceInfo{}
+	}
+	return s.devices
+}
+
+// DataPoints 返回已解析的数据点
+func (s *NCCLStreamParser) DataPoints() []ChartDataPoint {
+	return s.points
+}
+
+// RawLines 返回成功解析的数据行原始文本
+func (s *NCCLStreamParser) RawLines() []string {
+	return s.rawLines
+}
+
+// Unparsed 返回无法解析的数据行
+func (s *NCCLStreamParser) Unparsed() []UnparsedRow {
+	return s.unparsed
+}
+
+// Summary 返回当前数据点的汇总
+func (s *NCCLStreamParser) Summary() ResultSummary {
+	summary := summarizeResults(s.points)
+	summary.AvgBusbw = s.avgBusbw
+	summary.OutOfBounds = s.outOfBounds
+	summary.UnparsedRows = len(s.unparsed)
+	if s.outOfBounds != nil && *s.outOfBounds > 0 {
+		summary.Corrupted = true
+	}
+	return summary
 }
 
 // isTableHeader 检查是否为表头行
@@ -90,45 +334,92 @@ func (p *NCCLOutputParser) isTableEnd(line string) bool {
 	return p.tableEndRegex.MatchString(line)
 }
 
-// isDataLine 检查是否为有效的数据行
-// 使用正则表达式判断是否以数字开头（size 字段），排除 DEBUG/INFO 日志
+// isDataLine 检查是否为数据行
+// 使用正则表达式判断是否以整数开头（size 字段），排除 DEBUG/INFO 日志
 func (p *NCCLOutputParser) isDataLine(line string) bool {
 	return p.dataLineRegex.MatchString(line)
 }
 
-// parseDataLine 解析单行数据
-func (p *NCCLOutputParser) parseDataLine(line string) (ChartDataPoint, bool) {
-	if !p.isDataLine(line) {
-		return ChartDataPoint{}, false
+// parseTableLayout 根据表头行的列名生成列布局，无法识别时使用当前版本的默认布局
+func (p *NCCLOutputParser) parseTableLayout(line string) *tableLayout {
+	if layout, ok := newTableLayout(tableColumns(p.splitFields(line))); ok {
+		return layout
 	}
+	return defaultTableLayout()
+}
 
-	fields := p.splitFields(line)
-	if len(fields) < 12 {
-		return ChartDataPoint{}, false
+// parsePreamble 解析表格之前的一行运行信息，合并到 header 中，返回是否识别到了信息
+func (p *NCCLOutputParser) parsePreamble(line string, header *RunHeader) bool {
+	if p.parseRunParams(line, header) {
+		return true
 	}
 
-	size, err := strconv.Atoi(fields[0])
-	if err != nil || size <= 0 {
-		return ChartDataPoint{}, false
+	if m := p.runningTestRegex.FindStringSubmatch(line); m != nil {
+		header.Collective = m[1]
+		if m[2] != "" {
+			header.WorldSize, _ = strconv.Atoi(m[2])
+		}
+		return true
 	}
 
-	count, _ := strconv.Atoi(fields[1])
-	outAlgbw, _ := strconv.ParseFloat(fields[6], 64)
-	outBusbw, _ := strconv.ParseFloat(fields[7], 64)
-	inAlgbw, _ := strconv.ParseFloat(fields[10], 64)
-	inBusbw, _ := strconv.ParseFloat(fields[11], 64)
+	if m := p.collectiveRegex.FindStringSubmatch(line); m != nil {
+		header.Collective = m[1]
+		return true
+	}
+
+	// 每个 rank 都可能打印版本，只取第一次出现的
+	if m := p.ncclVersionRegex.FindStringSubmatch(line); m != nil && header.NCCLVersion == "" {
+		header.NCCLVersion = m[1]
+		header.CUDAVersion = m[2]
+		return true
+	}
+	return false
+}
 
-	dataPoint := ChartDataPoint{
-		Size:     size,
-		Count:    count,
-		Type:     fields[2],
-		OutAlgbw: outAlgbw,
-		OutBusbw: outBusbw,
-		InAlgbw:  inAlgbw,
-		InBusbw:  inBusbw,
+// parseRunParams 解析测试参数行
+func (p *NCCLOutputParser) parseRunParams(line string, header *RunHeader) bool {
+	m := p.runHeaderRegex.FindStringSubmatch(line)
+	if m == nil {
+		return false
 	}
 
-	return dataPoint, true
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	header.NThreads = atoi(m[1])
+	header.NGpus = atoi(m[2])
+	header.MinBytes, _ = strconv.ParseInt(m[3], 10, 64)
+	header.MaxBytes, _ = strconv.ParseInt(m[4], 10, 64)
+	header.Step = m[5]
+	header.WarmupIters = atoi(m[6])
+	header.Iters = atoi(m[7])
+	header.AggIters = atoi(m[8])
+	header.Validation = atoi(m[9])
+	header.Graph = atoi(m[10])
+	return true
+}
+
+// parseDeviceLine 解析设备列表中的一行
+func (p *NCCLOutputParser) parseDeviceLine(line string) (DeviceInfo, bool) {
+	m := p.deviceLineRegex.FindStringSubmatch(line)
+	if m == nil {
+		return DeviceInfo{}, false
+	}
+
+	rank, _ := strconv.Atoi(m[1])
+	group, _ := strconv.Atoi(m[2])
+	pid, _ := strconv.Atoi(m[3])
+	device, _ := strconv.Atoi(m[5])
+	return DeviceInfo{
+		Rank:   rank,
+		Group:  group,
+		Pid:    pid,
+		Host:   m[4],
+		Device: device,
+		BusID:  m[6],
+		Model:  m[7],
+	}, true
 }
 
 // splitFields 分割字段并过滤空字符串
@@ -158,25 +449,9 @@ func ExtractRawDataLines(output string) []string {
 		return []string{}
 	}
 
-	parser := NewNCCLOutputParser()
-	lines := strings.Split(output, "\n")
-	rawDataLines := []string{}
-	parsing := false
-
-	for _, line := range lines {
-		if parser.isTableHeader(line) {
-			parsing = true
-			continue
-		}
-
-		if parser.isTableEnd(line) {
-			break
-		}
-
-		if parsing && parser.isDataLine(line) {
-			rawDataLines = append(rawDataLines, line)
-		}
+	stream := NewNCCLStreamParser()
+	for _, line := range strings.Split(output, "\n") {
+		stream.Feed(line)
 	}
-
-	return rawDataLines
+	return stream.RawLines()
 }
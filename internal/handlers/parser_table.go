@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 表头中的列名（去掉 # 前缀并转为小写）
+const (
+	columnSize  = "size"
+	columnCount = "count"
+	columnType  = "type"
+	columnTime  = "time"
+	columnAlgbw = "algbw"
+	columnBusbw = "busbw"
+	columnWrong = "wrong" // 校验出错的元素数量，-c 0 时为 N/A
+)
+
+// defaultTableColumns 当前 nccl-tests 版本的表头，表头无法识别时按此解析
+var defaultTableColumns = []string{
+	"size", "count", "type", "redop", "root",
+	"time", "algbw", "busbw", "wrong",
+	"time", "algbw", "busbw", "wrong",
+}
+
+// UnparsedRow 表格中以整数开头、看起来是数据行但无法解析的行
+type UnparsedRow struct {
+	Line   int    `json:"line"` // 在解析的输出中的行号，从 1 开始
+	Text   string `json:"text"`
+	Reason string `json:"reason"`
+}
+
+// tableLayout 根据表头得到的列布局
+// 第一个 time 之前是公共列（size, count, type, redop, root 等），之后每个 time 开始一组计时列，
+// 依次为 out-of-place 和 in-place；不认识的列只参与列数校验
+type tableLayout struct {
+	columns []string
+	common  map[string]int
+	groups  []columnGroup
+}
+
+// columnGroup 一组计时列的位置，-1 表示该列不存在
+// 旧版本 nccl-tests 用 error 列报告最大误差，浮点类型下可能合法地非 0，不作为错误数量，
+// 超出容差的值由表格结束处的 Out of bounds values 报告
+type columnGroup struct {
+	time, algbw, busbw, wrong int
+}
+
+// newTableLayout 根据列名生成列布局，缺少 size 列或计时列时返回 false
+func newTableLayout(columns []string) (*tableLayout, bool) {
+	layout := &tableLayout{columns: columns, common: make(map[string]int)}
+
+	for i, name := range columns {
+		if name == columnTime {
+			layout.groups = append(layout.groups, columnGroup{time: i, algbw: -1, busbw: -1, wrong: -1})
+			continue
+		}
+		if len(layout.groups) == 0 {
+			if _, ok := layout.common[name]; !ok {
+				layout.common[name] = i
+			}
+			continue
+		}
+
+		group := &layout.groups[len(layout.groups)-1]
+		switch name {
+		case columnAlgbw:
+			group.algbw = i
+		case columnBusbw:
+			group.busbw = i
+		case columnWrong:
+			group.wrong = i
+		}
+	}
+
+	if _, ok := layout.common[columnSize]; !ok || len(layout.groups) == 0 {
+		return nil, false
+	}
+	return layout, true
+}
+
+// defaultTableLayout 返回当前 nccl-tests 版本的列布局
+func defaultTableLayout() *tableLayout {
+	layout, _ := newTableLayout(defaultTableColumns)
+	return layout
+}
+
+// tableColumns 从表头行提取列名，如 "#  size  count  type ... #wrong" 得到 size, count, type, ..., wrong
+func tableColumns(fields []string) []string {
+	columns := make([]string, 0, len(fields))
+	for _, field := range fields {
+		name := strings.ToLower(strings.TrimLeft(field, "#"))
+		if name != "" {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
+
+// parseRow 按列布局解析一行数据，失败时返回原因
+func (l *tableLayout) parseRow(fields []string) (ChartDataPoint, string) {
+	if len(fields) != len(l.columns) {
+		return ChartDataPoint{}, fmt.Sprintf("expected %d columns, got %d", len(l.columns), len(fields))
+	}
+
+	var point ChartDataPoint
+	var err error
+
+	if point.Size, err = strconv.Atoi(fields[l.common[columnSize]]); err != nil {
+		return ChartDataPoint{}, fmt.Sprintf("invalid size %q", fields[l.common[columnSize]])
+	}
+	if i, ok := l.common[columnCount]; ok {
+		if point.Count, err = strconv.Atoi(fields[i]); err != nil {
+			return ChartDataPoint{}, fmt.Sprintf("invalid count %q", fields[i])
+		}
+	}
+	if i, ok := l.common[columnType]; ok {
+		point.Type = fields[i]
+	}
+
+	// 只取前两组：out-of-place 和 in-place
+	targets := []struct {
+		time, algbw, busbw *float64
+		wrong              *int
+	}{
+		{&point.OutTime, &point.OutAlgbw, &point.OutBusbw, &point.OutWrong},
+		{&point.InTime, &point.InAlgbw, &point.InBusbw, &point.InWrong},
+	}
+	for g, group := range l.groups {
+		if g >= len(targets) {
+			break
+		}
+		target := targets[g]
+
+		for _, col := range []struct {
+			name  string
+			index int
+			value *float64
+		}{
+			{columnTime, group.time, target.time},
+			{columnAlgbw, group.algbw, target.algbw},
+			{columnBusbw, group.busbw, target.busbw},
+		} {
+			if col.index < 0 {
+				continue
+			}
+			if *col.value, err = strconv.ParseFloat(fields[col.index], 64); err != nil {
+				return ChartDataPoint{}, fmt.Sprintf("invalid %s %q", col.name, fields[col.index])
+			}
+		}
+
+		wrong, reason := parseWrong(fields, group)
+		if reason != "" {
+			return ChartDataPoint{}, reason
+		}
+		*target.wrong = wrong
+	}
+
+	return point, ""
+}
+
+// parseWrong 解析一组计时列的 #wrong，-c 0 未校验时为 N/A，按 0 处理
+func parseWrong(fields []string, group columnGroup) (int, string) {
+	if group.wrong < 0 || fields[group.wrong] == "N/A" {
+		return 0, ""
+	}
+	wrong, err := strconv.Atoi(fields[group.wrong])
+	if err != nil {
+		return 0, fmt.Sprintf("invalid #wrong %q", fields[group.wrong])
+	}
+	return wrong, ""
+}
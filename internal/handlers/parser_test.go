@@ -1,10 +1,19 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
+var updateGolden = flag.Bool("update", false, "更新 testdata 中的 golden 文件")
+
 const sampleNCCLOutput = `[cetus-g88-094] running nccl test all_reduce -b 1 -e 1G, world_size=16
 # nGpus(perProc) 1 minBytes 1 maxBytes 1073741824 step: 2(factor) warmup iters: 5 iters: 20 agg iters: 1 validation: 1 graph: 0
 #
@@ -125,3 +134,232 @@ func TestParseNCCLOutput(t *testing.T) {
 			i, d.Size, d.Count, d.Type, d.OutBusbw, d.InBusbw)
 	}
 }
+
+func TestParseRunResults(t *testing.T) {
+	results := ParseRunResults("run1", sampleNCCLOutput)
+	summary := results.Summary
+
+	// 原始数据行包含 size 为 0 的行，解析时会跳过
+	if summary.Points != len(results.DataPoints) || len(results.RawLines) != len(results.DataPoints)+1 {
+		t.Fatalf("points=%d data_points=%d raw_lines=%d", summary.Points, len(results.DataPoints), len(results.RawLines))
+	}
+	if summary.PeakBusbw != 121.29 || summary.PeakBusbwSize != 536870912 {
+		t.Errorf("peak busbw = %.2f @ %d, want 121.29 @ 536870912", summary.PeakBusbw, summary.PeakBusbwSize)
+	}
+	if summary.PeakInBusbw != 86.33 || summary.MaxSize != 1073741824 {
+		t.Errorf("peak in busbw = %.2f, max size = %d", summary.PeakInBusbw, summary.MaxSize)
+	}
+	if summary.AvgBusbw == nil || *summary.AvgBusbw != 15.9501 {
+		t.Errorf("avg busbw = %v, want 15.9501", summary.AvgBusbw)
+	}
+
+	if empty := ParseRunResults("run2", ""); empty.Summary.AvgBusbw != nil || empty.Summary.Points != 0 {
+		t.Errorf("unexpected summary for empty output: %+v", empty.Summary)
+	}
+}
+
+func TestParseCorruption(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		replace     [2]string // 替换示例输出中的文本
+		wrong       int
+		wrongSizes  []int
+		outOfBounds int
+		corrupted   bool
+	}{
+		{
+			desc: "没有数据损坏",
+		},
+		{
+			desc:       "#wrong 非 0",
+			replace:    [2]string{"   8299.2   64.69  121.29      0    11804   45.48   85.28      0", "   8299.2   64.69  121.29      3    11804   45.48   85.28      5"},
+			wrong:      8,
+			wrongSizes: []int{536870912},
+			corrupted:  true,
+		},
+		{
+			desc:        "越界值非 0",
+			replace:     [2]string{"# Out of bounds values : 0 OK", "# Out of bounds values : 12 FAILED"},
+			outOfBounds: 12,
+			corrupted:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			output := sampleNCCLOutput
+			if tc.replace[0] != "" {
+				output = strings.Replace(output, tc.replace[0], tc.replace[1], 1)
+			}
+			summary := ParseRunResults("run1", output).Summary
+
+			if summary.Wrong != tc.wrong || !reflect.DeepEqual(summary.WrongSizes, tc.wrongSizes) || summary.Corrupted != tc.corrupted {
+				t.Errorf("wrong=%d sizes=%v corrupted=%v, want %d %v %v",
+					summary.Wrong, summary.WrongSizes, summary.Corrupted, tc.wrong, tc.wrongSizes, tc.corrupted)
+			}
+			if summary.OutOfBounds == nil || *summary.OutOfBounds != tc.outOfBounds {
+				t.Errorf("out of bounds = %v, want %d", summary.OutOfBounds, tc.outOfBounds)
+			}
+			if (summary.CorruptionError() != "") != tc.corrupted {
+				t.Errorf("unexpected corruption error: %q", summary.CorruptionError())
+			}
+		})
+	}
+}
+
+func TestStreamParserEvents(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		output string
+		want   map[string]int // 各类事件的数量
+	}{
+		{
+			desc:   "完整输出",
+			output: sampleNCCLOutput,
+			want:   map[string]int{ParseEventHeader: 1, ParseEventDevices: 1, ParseEventDataPoint: 30, ParseEventSummary: 1},
+		},
+		{
+			desc:   "运行中途停止，结束时补发汇总",
+			output: sampleNCCLOutput[:strings.Index(sampleNCCLOutput, "        1024           512")],
+			want:   map[string]int{ParseEventHeader: 1, ParseEventDevices: 1, ParseEventDataPoint: 9, ParseEventSummary: 1},
+		},
+		{
+			desc:   "没有表格",
+			output: "mpirun: command not found",
+			want:   map[string]int{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			stream := NewNCCLStreamParser()
+			got := make(map[string]int)
+			for _, line := range strings.Split(tc.output, "\n") {
+				for _, event := range stream.Feed(line) {
+					got[event.Type]++
+				}
+			}
+			for _, event := range stream.Finish() {
+				got[event.Type]++
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("events = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	stream := NewNCCLStreamParser()
+	for _, line := range strings.Split(sampleNCCLOutput, "\n") {
+		stream.Feed(line)
+	}
+	if h := stream.Header(); h == nil || h.MaxBytes != 1073741824 || h.Iters != 20 || h.WarmupIters != 5 || h.Step != "2(factor)" {
+		t.Errorf("unexpected header: %+v", h)
+	}
+	if h := stream.Header(); h == nil || h.Collective != "all_reduce" || h.WorldSize != 16 || h.NCCLVersion != "2.27.7" || h.CUDAVersion != "12.4" {
+		t.Errorf("unexpected preamble: %+v", h)
+	}
+	if d := stream.Devices(); len(d) != 2 || d[1].Rank != 15 || d[1].Host != "cetus-g88-061" || d[1].Model != "NVIDIA H200" {
+		t.Errorf("unexpected devices: %+v", d)
+	}
+}
+
+func TestParsePreamble(t *testing.T) {
+	parser := NewNCCLOutputParser()
+
+	testCases := []struct {
+		desc string
+		line string
+		want RunHeader
+		ok   bool
+	}{
+		{
+			desc: "启动脚本信息",
+			line: "[node01] running nccl test all_gather -b 8 -e 128M, world_size=8",
+			want: RunHeader{Collective: "all_gather", WorldSize: 8},
+			ok:   true,
+		},
+		{
+			desc: "启动脚本信息没有 world_size",
+			line: "running nccl test sendrecv",
+			want: RunHeader{Collective: "sendrecv"},
+			ok:   true,
+		},
+		{
+			desc: "nccl-tests 打印的测试名称",
+			line: "# Collective test starting: reduce_scatter_perf",
+			want: RunHeader{Collective: "reduce_scatter"},
+			ok:   true,
+		},
+		{
+			desc: "NCCL INFO 日志中的版本",
+			line: "node01:41233:41233 [0] NCCL INFO NCCL version 2.21.5+cuda12.2",
+			want: RunHeader{NCCLVersion: "2.21.5", CUDAVersion: "12.2"},
+			ok:   true,
+		},
+		{
+			desc: "没有 CUDA 版本",
+			line: "NCCL version 2.18.3",
+			want: RunHeader{NCCLVersion: "2.18.3"},
+			ok:   true,
+		},
+		{
+			desc: "无关的日志",
+			line: "node01:41233:41233 [0] NCCL INFO Bootstrap : Using bond0:10.0.0.1<0>",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var header RunHeader
+			ok := parser.parsePreamble(tc.line, &header)
+			if ok != tc.ok || header != tc.want {
+				t.Errorf("parsePreamble = %+v, %v, want %+v, %v", header, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+// TestParseGolden 使用不同版本 nccl-tests 的输出校验解析结果：
+//   - legacy_*：早期版本，error 列报告最大误差，all_gather 没有 redop/root 列，设备行没有 Group
+//   - all_reduce、sendrecv、alltoall：当前格式，所有集合通信都有 redop/root 和 #wrong 列，含被日志截断的行
+//   - all_reduce_nocheck：-c 0 时 #wrong 为 N/A
+//   - perproc_all_reduce：较新版本的 nGpus(perProc) 参数行
+//
+// 修改解析逻辑后使用 go test -run TestParseGolden -update 更新 golden 文件，并检查差异
+func TestParseGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "parser", "*.txt"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no golden inputs found: %v", err)
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".txt")
+		t.Run(name, func(t *testing.T) {
+			output, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := json.MarshalIndent(ParseRunResults(name, string(output)), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(file, ".txt") + ".golden.json"
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("parse result differs from %s, run with -update and review the diff:\n%s", golden, got)
+			}
+		})
+	}
+}
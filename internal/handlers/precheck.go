@@ -13,10 +13,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-const (
-	// MaxConcurrency SSH 并发数
-	MaxConcurrency = 64
-)
+// MaxConcurrency SSH 并发数，可通过配置文件设置
+var MaxConcurrency = 64
 
 // NodeStatus 节点状态信息
 type NodeStatus struct {
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// historyResultsExt 解析结果文件后缀：<id>.results.json
+const historyResultsExt = ".results.json"
+
+// avgBusbwPattern 匹配 nccl-tests 输出末尾报告的平均总线带宽
+var avgBusbwPattern = regexp.MustCompile(`^\s*#\s*Avg bus bandwidth\s*:\s*([0-9.eE+-]+)`)
+
+// outOfBoundsPattern 匹配 nccl-tests 在表格结束处报告的越界值数量，如 # Out of bounds values : 0 OK
+var outOfBoundsPattern = regexp.MustCompile(`^\s*#\s*Out of bounds values\s*:\s*(\d+)`)
+
+// RunResults 从运行输出中解析出的结果
+type RunResults struct {
+	ID         string           `json:"id"`
+	Header     *RunHeader       `json:"header,omitempty"`   // 输出开头的运行信息，没有时为空
+	Devices    []DeviceInfo     `json:"devices"`            // 设备列表：每个 rank 的节点、GPU 和 PCI 总线 ID
+	Topology   *TopologyReport  `json:"topology,omitempty"` // 设备列表与 IP 列表、map_by 的检查结果，需要运行信息，保存历史时生成
+	DataPoints []ChartDataPoint `json:"data_points"`
+	RawLines   []string         `json:"raw_lines"`     // 成功解析的数据行原始文本
+	Unparsed   []UnparsedRow    `json:"unparsed_rows"` // 表格中无法解析的数据行
+	Summary    ResultSummary    `json:"summary"`
+}
+
+// ResultSummary 结果汇总
+type ResultSummary struct {
+	Points int `json:"points"` // 数据点数量
+	// 最小和最大测试大小（字节）
+	MinSize int `json:"min_size"`
+	MaxSize int `json:"max_size"`
+	// 峰值总线带宽（GB/s）及对应的测试大小，取 out-of-place 和 in-place 中较大的值
+	PeakBusbw     float64 `json:"peak_busbw"`
+	PeakBusbwSize int     `json:"peak_busbw_size"`
+	PeakOutBusbw  float64 `json:"peak_out_busbw"`
+	PeakInBusbw   float64 `json:"peak_in_busbw"`
+	PeakAlgbw     float64 `json:"peak_algbw"`
+	// nccl-tests 报告的平均总线带宽（GB/s），输出中没有时为 null
+	AvgBusbw *float64 `json:"avg_busbw"`
+	// 数据校验：#wrong 列的合计和出错的测试大小，以及 nccl-tests 报告的越界值数量（输出中没有时为 null）
+	Wrong       int   `json:"wrong"`
+	WrongSizes  []int `json:"wrong_sizes,omitempty"`
+	OutOfBounds *int  `json:"out_of_bounds"`
+	Corrupted   bool  `json:"corrupted"` // 有 #wrong 或越界值，表示网络或 GPU 上发生了数据损坏
+	// 表格中无法解析的数据行数量，详情见 RunResults.Unparsed
+	UnparsedRows int `json:"unparsed_rows"`
+}
+
+// ParseRunResults 解析运行输出，生成数据点、原始数据行和汇总
+func ParseRunResults(id, output string) RunResults {
+	stream := NewNCCLStreamParser()
+	for _, line := range strings.Split(output, "\n") {
+		stream.Feed(line)
+	}
+	stream.Finish()
+
+	return RunResults{
+		ID:         id,
+		Header:     stream.Header(),
+		Devices:    stream.Devices(),
+		DataPoints: stream.DataPoints(),
+		RawLines:   stream.RawLines(),
+		Unparsed:   stream.Unparsed(),
+		Summary:    stream.Summary(),
+	}
+}
+
+// parseAvgBusbw 解析 nccl-tests 报告的平均总线带宽行
+func parseAvgBusbw(line string) (float64, bool) {
+	m := avgBusbwPattern.FindStringSubmatch(line)
+	if m == nil {
+		return 0, false
+	}
+	avg, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return avg, true
+}
+
+// parseOutOfBounds 解析 nccl-tests 报告的越界值数量
+func parseOutOfBounds(line string) (int, bool) {
+	m := outOfBoundsPattern.FindStringSubmatch(line)
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// CorruptionError 返回数据损坏的描述，没有损坏时返回空字符串
+func (s ResultSummary) CorruptionError() string {
+	if !s.Corrupted {
+		return ""
+	}
+
+	var parts []string
+	if s.Wrong > 0 {
+		sizes := make([]string, 0, len(s.WrongSizes))
+		for _, size := range s.WrongSizes {
+			sizes = append(sizes, formatBytes(size))
+		}
+		parts = append(parts, fmt.Sprintf("%d wrong values at %s", s.Wrong, strings.Join(sizes, ", ")))
+	}
+	if s.OutOfBounds != nil && *s.OutOfBounds > 0 {
+		parts = append(parts, fmt.Sprintf("%d out of bounds values", *s.OutOfBounds))
+	}
+	return "Data corruption detected: " + strings.Join(parts, ", ")
+}
+
+// summarizeResults 计算数据点的汇总值
+func summarizeResults(points []ChartDataPoint) ResultSummary {
+	summary := ResultSummary{Points: len(points)}
+
+	for i, p := range points {
+		if i == 0 || p.Size < summary.MinSize {
+			summary.MinSize = p.Size
+		}
+		if p.Size > summary.MaxSize {
+			summary.MaxSize = p.Size
+		}
+
+		if p.OutBusbw > summary.PeakOutBusbw {
+			summary.PeakOutBusbw = p.OutBusbw
+		}
+		if p.InBusbw > summary.PeakInBusbw {
+			summary.PeakInBusbw = p.InBusbw
+		}
+		for _, busbw := range []float64{p.OutBusbw, p.InBusbw} {
+			if busbw > summary.PeakBusbw {
+				summary.PeakBusbw = busbw
+				summary.PeakBusbwSize = p.Size
+			}
+		}
+		for _, algbw := range []float64{p.OutAlgbw, p.InAlgbw} {
+			if algbw > summary.PeakAlgbw {
+				summary.PeakAlgbw = algbw
+			}
+		}
+
+		if wrong := p.OutWrong + p.InWrong; wrong > 0 {
+			summary.Wrong += wrong
+			summary.WrongSizes = append(summary.WrongSizes, p.Size)
+			summary.Corrupted = true
+		}
+	}
+	return summary
+}
+
+// writeHistoryResults 写入解析结果文件
+func writeHistoryResults(results RunResults) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode results: %v", err)
+	}
+
+	filename := filepath.Join(HistoryDir, results.ID+historyResultsExt)
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write results: %v", err)
+	}
+	return nil
+}
+
+// loadHistoryResults 读取历史记录的解析结果，旧版记录没有结果文件时从输出重新解析
+func loadHistoryResults(id string) (RunResults, error) {
+	data, err := os.ReadFile(filepath.Join(HistoryDir, id+historyResultsExt))
+	if err == nil {
+		var results RunResults
+		if err := json.Unmarshal(data, &results); err != nil {
+			return RunResults{}, fmt.Errorf("failed to parse results: %v", err)
+		}
+		return results, nil
+	}
+	if !os.IsNotExist(err) {
+		return RunResults{}, err
+	}
+
+	output, err := os.ReadFile(filepath.Join(HistoryDir, id+historyOutputExt))
+	if err != nil {
+		return RunResults{}, err
+	}
+	return ParseRunResults(id, string(output)), nil
+}
+
+// GetHistoryResults 获取历史记录的解析结果：数据点、原始数据行和汇总
+func GetHistoryResults(c *gin.Context) {
+	id, ok := historyID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid history ID",
+		})
+		return
+	}
+
+	results, err := loadHistoryResults(id)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "History record not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
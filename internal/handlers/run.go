@@ -0,0 +1,685 @@
+package handlers
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 运行状态
+const (
+	RunStatusQueued  = "queued"
+	RunStatusRunning = "running"
+	RunStatusSuccess = "success"
+	RunStatusError   = "error"
+	RunStatusTimeout = "timeout"
+	RunStatusStopped = "stopped"
+)
+
+const (
+	// MaxFinishedRuns 内存中保留的已结束运行数量，超出后淘汰最早的记录
+	MaxFinishedRuns = 100
+	// DefaultTimeout 未指定超时时间时使用的超时（秒），所有运行（包括活动的子运行）都有超时，避免卡住的进程一直占用节点
+	DefaultTimeout = 600
+)
+
+// RunRequest 创建运行的请求
+type RunRequest struct {
+	NCCLTestParams
+	Owner      string `json:"owner"`       // 发起人，未填写时使用 X-User 请求头或客户端 IP
+	OnConflict string `json:"on_conflict"` // 节点冲突时的处理方式：queue（默认，排队等待）或 reject（直接拒绝）
+
+	// Hosts 指定运行使用的节点，为空时从 IPListFile 读取
+	Hosts []string `json:"-"`
+	// Campaign 提交运行的活动 ID，只由活动设置
+	Campaign string `json:"-"`
+}
+
+// RunInfo 运行信息快照
+type RunInfo struct {
+	ID          string         `json:"id"`
+	Owner       string         `json:"owner"`
+	Campaign    string         `json:"campaign,omitempty"` // 所属活动 ID，只有活动的子运行才有
+	Status      string         `json:"status"`
+	Command     string         `json:"command"`
+	Launcher    string         `json:"launcher"`
+	Params      NCCLTestParams `json:"params"`
+	Env         []string       `json:"env"` // 传递给所有 rank 的实际环境变量
+	Hosts       []string       `json:"hosts"`
+	Timeout     int            `json:"timeout"` // 超时时间（秒）
+	Error       string         `json:"error,omitempty"`
+	ExitCode    int            `json:"exit_code"`
+	OutputLines int            `json:"output_lines"`
+
+	QueuePosition int      `json:"queue_position,omitempty"` // 排队位置，从 1 开始
+	BlockedBy     []string `json:"blocked_by,omitempty"`     // 占用了冲突节点的运行 ID
+
+	Thresholds []Threshold       `json:"thresholds,omitempty"` // 提交时展开的验收阈值（命名标准 + 请求中的阈值）
+	Acceptance *AcceptanceResult `json:"acceptance,omitempty"` // 运行结束后的验收结果，未指定验收标准时为空
+	Topology   *TopologyReport   `json:"topology,omitempty"`   // 设备列表检查结果，输出中没有设备列表时为空
+	Failures   []Failure         `json:"failures,omitempty"`   // 运行失败时按特征库识别的失败原因和处理建议
+
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Run 一次 NCCL 测试运行
+type Run struct {
+	mu       sync.Mutex
+	info     RunInfo
+	spec     *LaunchSpec
+	cmd      *exec.Cmd
+	log      *RunLog
+	logs     logIndex // 查询日志时增量解析的 NCCL 日志
+	done     chan struct{}
+	stopped  bool
+	timedOut bool
+}
+
+// Info 返回运行信息快照
+func (r *Run) Info() RunInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info := r.info
+	info.OutputLines = r.log.Len()
+	return info
+}
+
+// ID 返回运行 ID
+func (r *Run) ID() string {
+	return r.info.ID
+}
+
+// Log 返回运行输出日志
+func (r *Run) Log() *RunLog {
+	return r.log
+}
+
+// Done 返回运行结束时关闭的通道
+func (r *Run) Done() <-chan struct{} {
+	return r.done
+}
+
+// Finished 判断运行是否已结束
+func (r *Run) Finished() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// kill 停止运行中的进程，杀死整个进程组
+func (r *Run) kill() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cmd == nil || r.cmd.Process == nil {
+		return errors.New("run has no process")
+	}
+	if r.Finished() {
+		return errors.New("run already finished")
+	}
+
+	r.stopped = true
+	return killProcessGroup(r.cmd)
+}
+
+// timeoutKill 超时后杀死进程组
+func (r *Run) timeoutKill() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Finished() || r.cmd == nil || r.cmd.Process == nil {
+		return
+	}
+	r.timedOut = true
+	killProcessGroup(r.cmd)
+}
+
+// killProcessGroup 使用负的 PID 向整个进程组发送 SIGKILL
+func killProcessGroup(cmd *exec.Cmd) error {
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		return fmt.Errorf("failed to kill process group: %v", err)
+	}
+	return nil
+}
+
+// RunManager 运行注册表，管理所有运行中和最近结束的测试
+type RunManager struct {
+	mu       sync.Mutex
+	runs     map[string]*Run
+	order    []string          // 按创建时间排列的运行 ID
+	queue    []*Run            // 排队中的运行
+	reserved map[string]string // 节点 -> 占用该节点的运行 ID
+	tasks    sync.WaitGroup    // 读取输出和保存历史记录的后台任务
+}
+
+// NewRunManager 创建新的运行注册表
+func NewRunManager() *RunManager {
+	return &RunManager{
+		runs:     make(map[string]*Run),
+		reserved: make(map[string]string),
+	}
+}
+
+// defaultRunManager 全局运行注册表
+var defaultRunManager = NewRunManager()
+
+// Submit 提交一次新的运行，立即返回，不等待进程结束
+// 运行的节点与进行中或排队中的运行重叠时进入排队，或在 OnConflict 为 reject 时返回 HostConflictError
+func (m *RunManager) Submit(req RunRequest) (*Run, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if req.Timeout == 0 {
+		req.Timeout = DefaultTimeout
+	}
+
+	hosts := req.Hosts
+	if len(hosts) == 0 {
+		var err error
+		hosts, err = loadIPList(req.IPListFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("iplist file %s has no hosts", req.IPListFile)
+	}
+	if req.OnConflict != "" && req.OnConflict != ConflictQueue && req.OnConflict != ConflictReject {
+		return nil, fmt.Errorf("invalid on_conflict: %s", req.OnConflict)
+	}
+
+	launcher, err := getLauncher(req.Launcher)
+	if err != nil {
+		return nil, err
+	}
+
+	thresholds, err := resolveThresholds(req.NCCLTestParams)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newRunID()
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := launcher.Prepare(req.NCCLTestParams, hosts, runWorkDir(id))
+	if err != nil {
+		os.RemoveAll(runWorkDir(id))
+		return nil, err
+	}
+
+	run := &Run{
+		info: RunInfo{
+			ID:         id,
+			Owner:      req.Owner,
+			Campaign:   req.Campaign,
+			Status:     RunStatusQueued,
+			Command:    spec.Command,
+			Launcher:   launcher.Name(),
+			Env:        ncclEnv(req.NCCLTestParams),
+			Params:     req.NCCLTestParams,
+			Hosts:      hosts,
+			Timeout:    req.Timeout,
+			Thresholds: thresholds,
+			CreatedAt:  time.Now(),
+		},
+		spec: spec,
+		log:  NewRunLog(),
+		done: make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	blockers := m.blockers(hosts, len(m.queue))
+	if len(blockers) > 0 && req.OnConflict == ConflictReject {
+		os.RemoveAll(runWorkDir(id))
+		return nil, &HostConflictError{
+			Hosts:  m.conflictingHosts(hosts, len(m.queue)),
+			RunIDs: blockers,
+		}
+	}
+
+	m.runs[id] = run
+	m.order = append(m.order, id)
+
+	if len(blockers) == 0 {
+		m.launch(run)
+	} else {
+		m.queue = append(m.queue, run)
+		fmt.Printf("Run %s queued by %s, blocked by %v\n", id, req.Owner, blockers)
+		m.reindex()
+	}
+
+	return run, nil
+}
+
+// launch 预留节点并启动进程（调用方需持有 m.mu）
+func (m *RunManager) launch(run *Run) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	// 执行启动器生成的进程，合并 stdout 和 stderr
+	execCmd := exec.Command(run.spec.Argv[0], run.spec.Argv[1:]...)
+	if len(run.spec.Env) > 0 {
+		execCmd.Env = append(os.Environ(), run.spec.Env...)
+	}
+
+	// 设置进程组，以便能够杀死整个进程树
+	execCmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+
+	now := time.Now()
+	run.info.StartedAt = &now
+	run.info.QueuePosition = 0
+	run.info.BlockedBy = nil
+
+	stdout, err := execCmd.StdoutPipe()
+	if err == nil {
+		execCmd.Stderr = execCmd.Stdout
+		err = execCmd.Start()
+	}
+	if err != nil {
+		run.info.Status = RunStatusError
+		run.info.Error = fmt.Sprintf("Failed to start command: %v", err)
+		run.info.FinishedAt = &now
+		run.info.Failures = classifyRunFailures(run.info.ID, []string{run.info.Error})
+		logFailures(run.info.ID, run.info.Failures)
+		run.evaluateAcceptance(RunResults{ID: run.info.ID})
+		run.log.Close()
+		close(run.done)
+		os.RemoveAll(runWorkDir(run.info.ID))
+		fmt.Printf("Run %s failed to start: %v\n", run.info.ID, err)
+		m.saveHistory(run, run.info)
+		return
+	}
+
+	run.cmd = execCmd
+	run.info.Status = RunStatusRunning
+	m.reserve(run)
+
+	fmt.Printf("Run %s started by %s: %s\n", run.info.ID, run.info.Owner, execCmd.String())
+
+	m.tasks.Add(1)
+	go m.wait(run, stdout)
+}
+
+// wait 读取输出并等待进程结束，结束后释放节点并保存历史记录
+func (m *RunManager) wait(run *Run, stdout io.Reader) {
+	defer m.tasks.Done()
+
+	var timer *time.Timer
+	if run.info.Timeout > 0 {
+		timer = time.AfterFunc(time.Duration(run.info.Timeout)*time.Second, run.timeoutKill)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		run.log.Append(scanner.Text())
+	}
+
+	err := run.cmd.Wait()
+	if timer != nil {
+		timer.Stop()
+	}
+
+	finished := time.Now()
+	results := ParseRunResults(run.info.ID, run.log.String())
+
+	// 失败分类需要扫描整个日志，在持有 run.mu 之前完成，避免阻塞 Info() 和输出流
+	run.mu.Lock()
+	stopped := run.stopped
+	run.mu.Unlock()
+	var failures []Failure
+	if !stopped && (err != nil || results.Summary.Corrupted) {
+		lines, _, _, _ := run.log.Since(0)
+		failures = classifyRunFailures(run.info.ID, lines)
+	}
+
+	run.mu.Lock()
+	run.info.FinishedAt = &finished
+	if run.cmd.ProcessState != nil {
+		run.info.ExitCode = run.cmd.ProcessState.ExitCode()
+	}
+	switch {
+	case run.stopped:
+		run.info.Status = RunStatusStopped
+		run.info.Error = "Stopped by user"
+	case run.timedOut:
+		run.info.Status = RunStatusTimeout
+		run.info.Error = fmt.Sprintf("Command timed out after %d seconds", run.info.Timeout)
+	case err != nil:
+		run.info.Status = RunStatusError
+		run.info.Error = err.Error()
+	default:
+		run.info.Status = RunStatusSuccess
+	}
+	// 即使进程正常退出，数据损坏也视为运行失败
+	if msg := results.Summary.CorruptionError(); msg != "" {
+		fmt.Printf("Run %s: %s\n", run.info.ID, msg)
+		if run.info.Status == RunStatusSuccess {
+			run.info.Status = RunStatusError
+			run.info.Error = msg
+		} else {
+			run.info.Error += "; " + msg
+		}
+	}
+	run.info.Topology = CheckTopology(results.Devices, run.info.Hosts, run.info.Params)
+	if run.info.Topology != nil && !run.info.Topology.OK {
+		fmt.Printf("Run %s topology issues: %s\n", run.info.ID, strings.Join(run.info.Topology.Messages(), "; "))
+	}
+	if run.info.Status != RunStatusSuccess && run.info.Status != RunStatusStopped {
+		run.info.Failures = failures
+		logFailures(run.info.ID, failures)
+	}
+	run.evaluateAcceptance(results)
+	info := run.info
+	run.mu.Unlock()
+
+	run.log.Close()
+	close(run.done)
+	os.RemoveAll(runWorkDir(info.ID))
+
+	fmt.Printf("Run %s finished: %s\n", info.ID, info.Status)
+
+	// 释放节点，启动可以运行的排队任务
+	m.mu.Lock()
+	m.release(run)
+	m.schedule()
+	m.mu.Unlock()
+
+	m.saveHistory(run, run.Info())
+	m.evict()
+}
+
+// saveHistory 异步保存已结束运行的历史数据，info 为运行信息快照
+func (m *RunManager) saveHistory(run *Run, info RunInfo) {
+	output := info.Command + "\n\n" + run.log.String()
+	if info.Error != "" {
+		output += "\nError: " + info.Error
+	}
+	meta := newHistoryMeta(info, run.spec)
+
+	m.tasks.Add(1)
+	go func() {
+		defer m.tasks.Done()
+		if err := saveHistoryFile(meta, output); err != nil {
+			fmt.Printf("Failed to save history: %v\n", err)
+		}
+	}()
+}
+
+// Wait 等待所有后台任务（读取输出、保存历史记录）结束，调用方需先确保没有未结束的运行
+func (m *RunManager) Wait() {
+	m.tasks.Wait()
+}
+
+// logFailures 记录失败分类结果
+func logFailures(id string, failures []Failure) {
+	for _, failure := range failures {
+		fmt.Printf("Run %s failure %s on %v: %s\n", id, failure.Category, failure.Hosts, failure.Description)
+	}
+}
+
+// evaluateAcceptance 运行结束时检查验收阈值，未指定验收标准时不做检查，调用方需持有 run.mu
+func (run *Run) evaluateAcceptance(results RunResults) {
+	if run.info.Params.Criteria == "" && len(run.info.Thresholds) == 0 {
+		return
+	}
+	run.info.Acceptance = evaluateAcceptance(run.info.Params.Criteria, run.info.Thresholds, run.info.Status, results)
+}
+
+// Stop 停止运行：排队中的运行直接取消，运行中的运行杀死整个进程组
+func (m *RunManager) Stop(run *Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if run.Finished() {
+		return errors.New("run already finished")
+	}
+
+	if m.dequeue(run) {
+		now := time.Now()
+		run.mu.Lock()
+		run.info.Status = RunStatusStopped
+		run.info.Error = "Cancelled while queued"
+		run.info.QueuePosition = 0
+		run.info.BlockedBy = nil
+		run.info.FinishedAt = &now
+		run.evaluateAcceptance(RunResults{ID: run.info.ID})
+		info := run.info
+		run.mu.Unlock()
+
+		run.log.Close()
+		close(run.done)
+		os.RemoveAll(runWorkDir(info.ID))
+		m.saveHistory(run, info)
+
+		m.schedule()
+		return nil
+	}
+
+	return run.kill()
+}
+
+// evict 淘汰超出保留数量的已结束运行
+func (m *RunManager) evict() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	finished := 0
+	for i := len(m.order) - 1; i >= 0; i-- {
+		id := m.order[i]
+		if !m.runs[id].Finished() {
+			continue
+		}
+		finished++
+		if finished > MaxFinishedRuns {
+			delete(m.runs, id)
+			m.order = append(m.order[:i], m.order[i+1:]...)
+		}
+	}
+}
+
+// Get 根据 ID 获取运行
+func (m *RunManager) Get(id string) (*Run, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	run, ok := m.runs[id]
+	return run, ok
+}
+
+// List 返回所有运行，最新的在前
+func (m *RunManager) List() []*Run {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	runs := make([]*Run, 0, len(m.order))
+	for i := len(m.order) - 1; i >= 0; i-- {
+		runs = append(runs, m.runs[m.order[i]])
+	}
+	return runs
+}
+
+// Active 返回所有未结束（运行中或排队中）的测试，最新的在前
+func (m *RunManager) Active() []*Run {
+	var active []*Run
+	for _, run := range m.List() {
+		if !run.Finished() {
+			active = append(active, run)
+		}
+	}
+	return active
+}
+
+// newRunID 生成运行 ID：时间戳 + 随机后缀，如 20251120_143022_a1b2c3
+func newRunID() (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate run id: %v", err)
+	}
+	return time.Now().Format("20060102_150405") + "_" + hex.EncodeToString(suffix), nil
+}
+
+// requestOwner 获取发起人：优先使用请求体中的 owner，其次 X-User 请求头，最后客户端 IP
+func requestOwner(c *gin.Context, owner string) string {
+	if owner != "" {
+		return owner
+	}
+	if user := c.GetHeader("X-User"); user != "" {
+		return user
+	}
+	return c.ClientIP()
+}
+
+// CreateRun 创建一次异步运行，立即返回运行 ID
+func CreateRun(c *gin.Context) {
+	var req RunRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Owner = requestOwner(c, req.Owner)
+
+	run, err := defaultRunManager.Submit(req)
+	if err != nil {
+		respondSubmitError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, run.Info())
+}
+
+// ListRuns 获取运行列表
+func ListRuns(c *gin.Context) {
+	runs := defaultRunManager.List()
+
+	infos := make([]RunInfo, 0, len(runs))
+	for _, run := range runs {
+		infos = append(infos, run.Info())
+	}
+
+	// 运行中的排在前面，其次是排队中的
+	rank := func(status string) int {
+		switch status {
+		case RunStatusRunning:
+			return 0
+		case RunStatusQueued:
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return rank(infos[i].Status) < rank(infos[j].Status)
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"count": len(infos),
+		"runs":  infos,
+	})
+}
+
+// GetRun 获取指定运行的信息
+func GetRun(c *gin.Context) {
+	run, ok := defaultRunManager.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Run not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, run.Info())
+}
+
+// GetRunLog 获取指定运行的输出，offset 为起始行号，便于脚本轮询
+func GetRunLog(c *gin.Context) {
+	run, ok := defaultRunManager.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Run not found",
+		})
+		return
+	}
+
+	offset := 0
+	if s := c.Query("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid offset",
+			})
+			return
+		}
+		offset = n
+	}
+
+	lines, next, _, closed := run.Log().Since(offset)
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":       run.ID(),
+		"status":   run.Info().Status,
+		"lines":    lines,
+		"offset":   offset,
+		"next":     next,
+		"finished": closed,
+	})
+}
+
+// StopRun 停止指定运行
+func StopRun(c *gin.Context) {
+	run, ok := defaultRunManager.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Run not found",
+		})
+		return
+	}
+
+	if run.Finished() {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "finished",
+			"message": "Run already finished",
+		})
+		return
+	}
+
+	if err := defaultRunManager.Stop(run); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      run.ID(),
+		"status":  "stopped",
+		"message": "NCCL test stopped successfully",
+	})
+}
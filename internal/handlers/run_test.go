@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubLauncher 测试用启动器：进程输出一行后执行 script，$1 为工作目录下的 exit 文件
+type stubLauncher struct {
+	name   string
+	script string
+}
+
+func (l *stubLauncher) Name() string {
+	return l.name
+}
+
+func (l *stubLauncher) Prepare(params NCCLTestParams, hosts []string, workDir string) (*LaunchSpec, error) {
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return nil, err
+	}
+	groups := [][]string{
+		{"sh", "-c", "echo started; " + l.script},
+		{"stub", filepath.Join(workDir, "exit")},
+	}
+	return &LaunchSpec{Argv: flattenArgs(groups), Command: renderCommand(groups)}, nil
+}
+
+// missingLauncher 测试用启动器：可执行文件不存在，进程无法启动
+type missingLauncher struct{}
+
+func (l *missingLauncher) Name() string {
+	return "missing"
+}
+
+func (l *missingLauncher) Prepare(params NCCLTestParams, hosts []string, workDir string) (*LaunchSpec, error) {
+	return &LaunchSpec{Argv: []string{"/nonexistent/nccl_test"}, Command: "/nonexistent/nccl_test"}, nil
+}
+
+func init() {
+	RegisterLauncher(&missingLauncher{})
+	// stub 等待 exit 文件出现后以文件内容作为退出码，stub-exit 立即退出
+	RegisterLauncher(&stubLauncher{name: "stub", script: `while [ ! -e "$1" ]; do sleep 0.01; done; exit "$(cat "$1")"`})
+	RegisterLauncher(&stubLauncher{name: "stub-exit", script: "exit 0"})
+}
+
+// newTestRunManager 创建使用临时数据目录和历史目录的运行注册表
+func newTestRunManager(t *testing.T) *RunManager {
+	oldData, oldHistory := DataDir, HistoryDir
+	DataDir, HistoryDir = t.TempDir(), t.TempDir()
+	m := NewRunManager()
+	t.Cleanup(func() {
+		// 停止剩余的运行，等待后台任务结束后再恢复目录，避免写入已删除的临时目录
+		for _, run := range m.Active() {
+			m.Stop(run)
+		}
+		m.Wait()
+		DataDir, HistoryDir = oldData, oldHistory
+	})
+	return m
+}
+
+// submitStub 在指定节点上提交一次 stub 运行
+func submitStub(t *testing.T, m *RunManager, owner string, hosts ...string) *Run {
+	t.Helper()
+	params := validParams()
+	params.Launcher = "stub"
+	run, err := m.Submit(RunRequest{NCCLTestParams: params, Owner: owner, Hosts: hosts})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	return run
+}
+
+// releaseStub 让 stub 运行以指定的退出码结束，先写临时文件再重命名，避免进程读到空文件
+func releaseStub(t *testing.T, run *Run, code int) {
+	t.Helper()
+	exit := filepath.Join(runWorkDir(run.ID()), "exit")
+	if err := os.WriteFile(exit+".tmp", []byte(strconv.Itoa(code)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(exit+".tmp", exit); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// finishStub 让 stub 运行以指定的退出码结束，并等待运行结束
+func finishStub(t *testing.T, run *Run, code int) {
+	t.Helper()
+	releaseStub(t, run, code)
+	waitRun(t, run)
+}
+
+// waitRun 等待运行结束
+func waitRun(t *testing.T, run *Run) {
+	t.Helper()
+	select {
+	case <-run.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("run %s did not finish", run.ID())
+	}
+}
+
+// waitHistory 等待运行的历史记录保存完成，此时运行释放的节点已经重新调度
+func waitHistory(t *testing.T, run *Run) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if meta, err := loadHistoryMeta(run.ID()); err == nil && meta != nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("history of run %s was not saved", run.ID())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitStarted 等待运行启动并输出第一行
+func waitStarted(t *testing.T, run *Run) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for run.Info().Status == RunStatusQueued || run.Log().Len() == 0 {
+		if run.Finished() || time.Now().After(deadline) {
+			t.Fatalf("run %s did not start: %+v", run.ID(), run.Info())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStopNCCLTest(t *testing.T) {
+	m := newTestRunManager(t)
+	oldManager := defaultRunManager
+	defaultRunManager = m
+	defer func() { defaultRunManager = oldManager }()
+
+	mine := submitStub(t, m, "alice", "node01")
+	others := submitStub(t, m, "bob", "node02")
+
+	gin.SetMode(gin.TestMode)
+	stop := func(user string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/nccl/stop", nil)
+		c.Request.Header.Set("X-User", user)
+		StopNCCLTest(c)
+		return w
+	}
+
+	// 未指定 id 时只停止自己的运行
+	if w := stop("carol"); w.Code != http.StatusOK || others.Finished() || mine.Finished() {
+		t.Fatalf("carol should not stop any run: %s", w.Body.String())
+	}
+	if w := stop("alice"); w.Code != http.StatusOK {
+		t.Fatalf("alice stop: %d %s", w.Code, w.Body.String())
+	}
+	waitRun(t, mine)
+	if mine.Info().Status != RunStatusStopped || others.Finished() {
+		t.Errorf("mine = %s, others finished = %v", mine.Info().Status, others.Finished())
+	}
+
+	// 活动的子运行只能通过活动停止
+	params := validParams()
+	params.Launcher = "stub"
+	sub, err := m.Submit(RunRequest{NCCLTestParams: params, Owner: "alice", Hosts: []string{"node03"}, Campaign: "20251120_143022_a1b2c3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := stop("alice"); w.Code != http.StatusOK || sub.Finished() {
+		t.Errorf("campaign run should not be stopped: %s", w.Body.String())
+	}
+}
+
+func TestSubmitTimeout(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		timeout int
+		want    int
+	}{
+		{"未指定时使用默认超时", 0, DefaultTimeout},
+		{"指定超时", 30, 30},
+	}
+
+	m := newTestRunManager(t)
+	for i, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			params := validParams()
+			params.Launcher = "stub"
+			params.Timeout = tc.timeout
+			run, err := m.Submit(RunRequest{NCCLTestParams: params, Hosts: []string{fmt.Sprintf("node%02d", i)}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := run.Info().Timeout; got != tc.want {
+				t.Errorf("timeout = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+func TestRunLifecycle(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		timeout  int
+		finish   func(t *testing.T, m *RunManager, run *Run)
+		status   string
+		exitCode int
+	}{
+		{"正常结束", 0, func(t *testing.T, m *RunManager, run *Run) { finishStub(t, run, 0) }, RunStatusSuccess, 0},
+		{"非零退出码", 0, func(t *testing.T, m *RunManager, run *Run) { finishStub(t, run, 3) }, RunStatusError, 3},
+		{"超时", 1, func(t *testing.T, m *RunManager, run *Run) { waitRun(t, run) }, RunStatusTimeout, -1},
+		{"停止", 0, func(t *testing.T, m *RunManager, run *Run) {
+			if err := m.Stop(run); err != nil {
+				t.Fatalf("Stop: %v", err)
+			}
+			waitRun(t, run)
+		}, RunStatusStopped, -1},
+	}
+
+	m := newTestRunManager(t)
+	for i, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			host := fmt.Sprintf("node%02d", i)
+			blocker := submitStub(t, m, "alice", host)
+
+			// 提交后立即返回，节点被占用时进入排队
+			params := validParams()
+			params.Launcher = "stub"
+			params.Timeout = tc.timeout
+			run, err := m.Submit(RunRequest{NCCLTestParams: params, Owner: "bob", Hosts: []string{host}})
+			if err != nil {
+				t.Fatalf("Submit: %v", err)
+			}
+			if run.ID() == "" || run.Finished() || run.Info().Status != RunStatusQueued {
+				t.Fatalf("run should be queued: %+v", run.Info())
+			}
+
+			finishStub(t, blocker, 0)
+			waitStarted(t, run)
+			if info := run.Info(); info.Status != RunStatusRunning || info.StartedAt == nil || info.QueuePosition != 0 {
+				t.Fatalf("run should be running after the blocker finished: %+v", info)
+			}
+
+			tc.finish(t, m, run)
+			info := run.Info()
+			if info.Status != tc.status || info.ExitCode != tc.exitCode || info.FinishedAt == nil {
+				t.Errorf("status = %s, exit code = %d, want %s, %d", info.Status, info.ExitCode, tc.status, tc.exitCode)
+			}
+			if info.OutputLines != 1 {
+				t.Errorf("output lines = %d, want 1", info.OutputLines)
+			}
+
+			// 已结束的运行不能再停止
+			if err := m.Stop(run); err == nil {
+				t.Error("stopping a finished run should fail")
+			}
+		})
+	}
+}
+
+func TestRunEviction(t *testing.T) {
+	m := newTestRunManager(t)
+
+	params := validParams()
+	params.Launcher = "stub-exit"
+	runs := make([]*Run, MaxFinishedRuns+1)
+	for i := range runs {
+		run, err := m.Submit(RunRequest{NCCLTestParams: params, Hosts: []string{fmt.Sprintf("node%03d", i)}})
+		if err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+		runs[i] = run
+	}
+	for _, run := range runs {
+		waitRun(t, run)
+	}
+
+	// 每个运行结束后都会淘汰，最后一次淘汰可能晚于 Done
+	deadline := time.Now().Add(5 * time.Second)
+	for len(m.List()) > MaxFinishedRuns && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(m.List()); n != MaxFinishedRuns {
+		t.Fatalf("runs = %d, want %d", n, MaxFinishedRuns)
+	}
+	if _, ok := m.Get(runs[0].ID()); ok {
+		t.Error("the oldest finished run should be evicted")
+	}
+	if _, ok := m.Get(runs[len(runs)-1].ID()); !ok {
+		t.Error("the newest run should be kept")
+	}
+}
+
+func TestLaunchFailure(t *testing.T) {
+	m := newTestRunManager(t)
+
+	params := validParams()
+	params.Launcher = "missing"
+	params.Thresholds = []Threshold{{Metric: "peak_busbw", Op: ">=", Value: 100}}
+	run, err := m.Submit(RunRequest{NCCLTestParams: params, Hosts: []string{"node01"}})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	waitRun(t, run)
+	m.Wait()
+
+	// 无法启动的运行同样给出验收结果和失败原因，并保存历史记录
+	info := run.Info()
+	if info.Status != RunStatusError || info.Acceptance == nil || info.Acceptance.Result != "FAIL" {
+		t.Errorf("run should fail acceptance: status=%s acceptance=%+v", info.Status, info.Acceptance)
+	}
+	if len(info.Failures) == 0 || info.Failures[0].Signature != "launcher_not_found" {
+		t.Errorf("failures = %+v", info.Failures)
+	}
+	meta, err := loadHistoryMeta(run.ID())
+	if err != nil || meta == nil || meta.Acceptance == nil || meta.Acceptance.Result != "FAIL" {
+		t.Errorf("history should record the failed acceptance: %+v, %v", meta, err)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"strings"
+	"sync"
+)
+
+// RunLog 运行输出日志，按行追加，支持多个读者同时跟随
+type RunLog struct {
+	mu     sync.Mutex
+	lines  []string
+	closed bool
+	notify chan struct{} // 每次追加或关闭时关闭并替换，用于唤醒等待中的读者
+}
+
+// NewRunLog 创建新的运行输出日志
+func NewRunLog() *RunLog {
+	return &RunLog{
+		notify: make(chan struct{}),
+	}
+}
+
+// Append 追加一行输出
+func (l *RunLog) Append(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return
+	}
+	l.lines = append(l.lines, line)
+	l.wake()
+}
+
+// Close 标记日志结束，之后不再追加
+func (l *RunLog) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return
+	}
+	l.closed = true
+	l.wake()
+}
+
+// wake 唤醒所有等待中的读者（调用方需持有锁）
+func (l *RunLog) wake() {
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+// Since 返回从 offset 行开始的输出
+// next 为下一次读取的偏移；当没有新内容且日志未结束时，可等待 wait 通道被关闭
+func (l *RunLog) Since(offset int) (lines []string, next int, wait <-chan struct{}, closed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(l.lines) {
+		offset = len(l.lines)
+	}
+
+	lines = append([]string(nil), l.lines[offset:]...)
+	return lines, len(l.lines), l.notify, l.closed
+}
+
+// Len 返回当前行数
+func (l *RunLog) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.lines)
+}
+
+// String 返回完整输出文本
+func (l *RunLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.lines) == 0 {
+		return ""
+	}
+	return strings.Join(l.lines, "\n") + "\n"
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// sseKeepAliveInterval SSE 心跳间隔，防止代理断开空闲连接
+	sseKeepAliveInterval = 15 * time.Second
+)
+
+// setSSEHeaders 设置流式输出的响应头
+func setSSEHeaders(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+}
+
+// sendRunHeader 发送运行 ID、命令信息，排队中的运行额外发送排队位置
+func sendRunHeader(c *gin.Context, run *Run) {
+	info := run.Info()
+	c.SSEvent("run", info.ID)
+	c.SSEvent("command", info.Command)
+	if info.Status == RunStatusQueued {
+		c.SSEvent("queued", gin.H{
+			"position":   info.QueuePosition,
+			"blocked_by": info.BlockedBy,
+		})
+	}
+	c.Writer.Flush()
+}
+
+// followRun 从 offset 行开始回放运行输出并跟随实时输出，直到运行结束或客户端断开
+// 每个 output 事件的 id 为该行之后的偏移，客户端可通过 Last-Event-ID 从断点继续
+// 输出同时交给增量解析器，识别到的测试参数、设备列表、数据点和汇总作为 header、devices、datapoint、summary 事件发送
+// 返回 false 表示客户端已断开；断开不会影响运行本身
+func followRun(c *gin.Context, run *Run, offset int) bool {
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	// 每个客户端使用独立的解析器；断点续传时先用之前的输出恢复解析状态，不重复发送事件
+	parser := NewNCCLStreamParser()
+	if offset > 0 {
+		seen, _, _, _ := run.Log().Since(0)
+		if offset < len(seen) {
+			seen = seen[:offset]
+		}
+		for _, line := range seen {
+			parser.Feed(line)
+		}
+	}
+
+	for {
+		lines, next, wait, closed := run.Log().Since(offset)
+		for i, line := range lines {
+			c.Render(-1, sse.Event{
+				Id:    strconv.Itoa(offset + i + 1),
+				Event: "output",
+				Data:  line,
+			})
+			sendParseEvents(c, run, parser.Feed(line))
+		}
+		if len(lines) > 0 {
+			c.Writer.Flush()
+		}
+		offset = next
+
+		if closed {
+			break
+		}
+
+		select {
+		case <-wait:
+		case <-keepAlive.C:
+			if _, err := c.Writer.WriteString(": keepalive\n\n"); err != nil {
+				return false
+			}
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return false
+		}
+	}
+
+	sendParseEvents(c, run, parser.Finish())
+
+	info := run.Info()
+	if info.Status != RunStatusSuccess {
+		c.SSEvent("error", gin.H{"message": fmt.Sprintf("Error: %s", info.Error), "status": info.Status, "failures": info.Failures})
+	} else {
+		c.SSEvent("done", "Command completed successfully")
+	}
+	c.Writer.Flush()
+	return true
+}
+
+// sendParseEvents 发送增量解析产生的事件，数据为 JSON；设备列表之后紧接着发送拓扑检查结果
+func sendParseEvents(c *gin.Context, run *Run, events []ParseEvent) {
+	for _, event := range events {
+		c.SSEvent(event.Type, event.Data)
+
+		if devices, ok := event.Data.([]DeviceInfo); ok {
+			info := run.Info()
+			if report := CheckTopology(devices, info.Hosts, info.Params); report != nil {
+				c.SSEvent(TopologyEvent, report)
+			}
+		}
+	}
+}
+
+// StreamRun 流式获取指定运行的输出
+// 支持通过 offset 查询参数或 Last-Event-ID 请求头从指定行继续，之后跟随实时输出
+// EventSource 重连时保留原来的 URL，因此 Last-Event-ID 优先于 offset
+// 多个客户端可同时观看同一运行
+func StreamRun(c *gin.Context) {
+	run, ok := defaultRunManager.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Run not found",
+		})
+		return
+	}
+
+	offset := 0
+	resume := c.GetHeader("Last-Event-ID")
+	if resume == "" {
+		resume = c.Query("offset")
+	}
+	if resume != "" {
+		n, err := strconv.Atoi(resume)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid offset",
+			})
+			return
+		}
+		offset = n
+	}
+
+	setSSEHeaders(c)
+
+	// 仅在从头开始时发送命令信息，断点续传时不重复发送
+	if offset == 0 {
+		sendRunHeader(c, run)
+	}
+
+	followRun(c, run, offset)
+}
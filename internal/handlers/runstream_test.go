@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseEvent 流式输出中的一个事件
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// newStreamTestServer 创建只包含 /runs/:id/stream 的测试服务，运行的输出由测试直接写入
+func newStreamTestServer(t *testing.T) (*httptest.Server, *Run) {
+	m := NewRunManager()
+	run := &Run{
+		info: RunInfo{ID: "20251120_143022_a1b2c3", Status: RunStatusRunning, Command: "nccl_test"},
+		log:  NewRunLog(),
+		done: make(chan struct{}),
+	}
+	m.runs[run.ID()] = run
+	m.order = append(m.order, run.ID())
+
+	oldManager := defaultRunManager
+	defaultRunManager = m
+	t.Cleanup(func() { defaultRunManager = oldManager })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/runs/:id/stream", StreamRun)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, run
+}
+
+// finishStreamRun 结束手动写入的运行
+func finishStreamRun(run *Run) {
+	run.mu.Lock()
+	run.info.Status = RunStatusSuccess
+	run.mu.Unlock()
+	run.log.Close()
+	close(run.done)
+}
+
+// readStream 连接流式输出并读取事件，stop 返回 true 时断开连接
+func readStream(t *testing.T, url, lastEventID string, stop func(events []sseEvent) bool) (int, []sseEvent) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var events []sseEvent
+	var event sseEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if event.event != "" {
+				events = append(events, event)
+				if stop != nil && stop(events) {
+					break
+				}
+			}
+			event = sseEvent{}
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "id:"); ok {
+			event.id = v
+		} else if v, ok := strings.CutPrefix(line, "event:"); ok {
+			event.event = v
+		} else if v, ok := strings.CutPrefix(line, "data:"); ok {
+			event.data = v
+		}
+	}
+	return resp.StatusCode, events
+}
+
+// outputLines 返回 output 事件的 id 和内容
+func outputLines(events []sseEvent) (ids, lines []string) {
+	for _, event := range events {
+		if event.event == "output" {
+			ids = append(ids, event.id)
+			lines = append(lines, event.data)
+		}
+	}
+	return ids, lines
+}
+
+func TestStreamRunResume(t *testing.T) {
+	server, run := newStreamTestServer(t)
+	for i := 1; i <= 5; i++ {
+		run.log.Append(fmt.Sprintf("line %d", i))
+	}
+	finishStreamRun(run)
+
+	testCases := []struct {
+		desc        string
+		query       string
+		lastEventID string
+		status      int
+		ids         []string // 期望的 output 事件 id，内容为 line <id>
+		header      bool     // 是否发送 run 事件
+	}{
+		{"从头开始", "", "", http.StatusOK, []string{"1", "2", "3", "4", "5"}, true},
+		{"offset 查询参数", "?offset=2", "", http.StatusOK, []string{"3", "4", "5"}, false},
+		{"Last-Event-ID 优先于 offset", "?offset=0", "3", http.StatusOK, []string{"4", "5"}, false},
+		{"偏移超出输出", "?offset=9", "", http.StatusOK, nil, false},
+		{"非法 offset", "?offset=-1", "", http.StatusBadRequest, nil, false},
+		{"非法 Last-Event-ID", "?offset=2", "x", http.StatusBadRequest, nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			status, events := readStream(t, server.URL+"/runs/"+run.ID()+"/stream"+tc.query, tc.lastEventID, nil)
+			if status != tc.status {
+				t.Fatalf("status = %d, want %d", status, tc.status)
+			}
+			if status != http.StatusOK {
+				return
+			}
+
+			ids, lines := outputLines(events)
+			var want []string
+			for _, id := range tc.ids {
+				want = append(want, "line "+id)
+			}
+			if !reflect.DeepEqual(ids, tc.ids) || !reflect.DeepEqual(lines, want) {
+				t.Errorf("ids = %v, lines = %v", ids, lines)
+			}
+			if header := len(events) > 0 && events[0].event == "run"; header != tc.header {
+				t.Errorf("run event sent = %v, want %v", header, tc.header)
+			}
+			if last := events[len(events)-1]; last.event != "done" {
+				t.Errorf("last event = %+v, want done", last)
+			}
+		})
+	}
+}
+
+func TestStreamRunReconnect(t *testing.T) {
+	server, run := newStreamTestServer(t)
+	url := server.URL + "/runs/" + run.ID() + "/stream"
+
+	// 一边写入输出一边断开重连，重连使用最后收到的事件 id
+	const total = 300
+	go func() {
+		for i := 1; i <= total; i++ {
+			run.log.Append("line " + strconv.Itoa(i))
+			if i%10 == 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		finishStreamRun(run)
+	}()
+
+	var received []string
+	lastID := ""
+	for attempt := 0; ; attempt++ {
+		if attempt > total {
+			t.Fatal("stream did not finish")
+		}
+		done := false
+		_, events := readStream(t, url, lastID, func(events []sseEvent) bool {
+			last := events[len(events)-1]
+			done = last.event == "done"
+			// 每次收到 7 行后断开
+			ids, _ := outputLines(events)
+			return len(ids) >= 7
+		})
+
+		ids, lines := outputLines(events)
+		received = append(received, lines...)
+		if len(ids) > 0 {
+			lastID = ids[len(ids)-1]
+		}
+		if done {
+			break
+		}
+	}
+
+	if len(received) != total {
+		t.Fatalf("received %d lines, want %d", len(received), total)
+	}
+	for i, line := range received {
+		if want := "line " + strconv.Itoa(i+1); line != want {
+			t.Fatalf("line %d = %q, want %q", i, line, want)
+		}
+	}
+}
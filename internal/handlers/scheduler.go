@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 节点冲突时的处理方式
+const (
+	ConflictQueue  = "queue"
+	ConflictReject = "reject"
+)
+
+// HostConflictError 运行节点与其他运行冲突
+type HostConflictError struct {
+	Hosts  []string // 冲突的节点
+	RunIDs []string // 占用这些节点的运行
+}
+
+func (e *HostConflictError) Error() string {
+	return fmt.Sprintf("hosts %s are in use by runs %s",
+		strings.Join(e.Hosts, ","), strings.Join(e.RunIDs, ","))
+}
+
+// MoveQueueRequest 调整排队位置的请求
+type MoveQueueRequest struct {
+	Position int `json:"position" binding:"required,min=1"` // 目标位置，从 1 开始
+}
+
+// blockers 返回与 hosts 冲突的运行 ID：占用节点的运行中任务，以及队列前 upto 个中节点重叠的排队任务
+// 调用方需持有 m.mu
+func (m *RunManager) blockers(hosts []string, upto int) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, host := range hosts {
+		if id, ok := m.reserved[host]; ok {
+			add(id)
+		}
+	}
+	for _, queued := range m.queue[:upto] {
+		if hostsOverlap(hosts, queued.info.Hosts) {
+			add(queued.info.ID)
+		}
+	}
+	return ids
+}
+
+// conflictingHosts 返回 hosts 中已被占用或被队列前 upto 个任务请求的节点（调用方需持有 m.mu）
+func (m *RunManager) conflictingHosts(hosts []string, upto int) []string {
+	wanted := make(map[string]bool)
+	for _, queued := range m.queue[:upto] {
+		for _, host := range queued.info.Hosts {
+			wanted[host] = true
+		}
+	}
+
+	var conflicts []string
+	for _, host := range hosts {
+		if _, ok := m.reserved[host]; ok || wanted[host] {
+			conflicts = append(conflicts, host)
+		}
+	}
+	return conflicts
+}
+
+// reserve 为运行预留节点（调用方需持有 m.mu）
+func (m *RunManager) reserve(run *Run) {
+	for _, host := range run.info.Hosts {
+		m.reserved[host] = run.info.ID
+	}
+}
+
+// release 释放运行占用的节点（调用方需持有 m.mu）
+func (m *RunManager) release(run *Run) {
+	for _, host := range run.info.Hosts {
+		if m.reserved[host] == run.info.ID {
+			delete(m.reserved, host)
+		}
+	}
+}
+
+// schedule 按队列顺序启动节点空闲的排队任务（调用方需持有 m.mu）
+// 排在前面但无法启动的任务所请求的节点不会被后面的任务抢占，避免大任务饿死
+func (m *RunManager) schedule() {
+	var remaining []*Run
+	wanted := make(map[string]bool)
+
+	for _, run := range m.queue {
+		free := true
+		for _, host := range run.info.Hosts {
+			if _, ok := m.reserved[host]; ok || wanted[host] {
+				free = false
+				break
+			}
+		}
+
+		if free {
+			m.launch(run)
+			continue
+		}
+
+		remaining = append(remaining, run)
+		for _, host := range run.info.Hosts {
+			wanted[host] = true
+		}
+	}
+
+	m.queue = remaining
+	m.reindex()
+}
+
+// reindex 更新排队任务的位置和阻塞信息（调用方需持有 m.mu）
+func (m *RunManager) reindex() {
+	for i, run := range m.queue {
+		blockers := m.blockers(run.info.Hosts, i)
+
+		run.mu.Lock()
+		run.info.QueuePosition = i + 1
+		run.info.BlockedBy = blockers
+		run.mu.Unlock()
+	}
+}
+
+// dequeue 从队列中移除运行，返回运行是否在队列中（调用方需持有 m.mu）
+func (m *RunManager) dequeue(run *Run) bool {
+	for i, queued := range m.queue {
+		if queued == run {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Move 调整排队任务的位置，position 从 1 开始，超出范围时移动到队尾
+func (m *RunManager) Move(run *Run, position int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.dequeue(run) {
+		return errors.New("run is not queued")
+	}
+
+	index := position - 1
+	if index < 0 {
+		index = 0
+	}
+	if index > len(m.queue) {
+		index = len(m.queue)
+	}
+
+	m.queue = append(m.queue, nil)
+	copy(m.queue[index+1:], m.queue[index:])
+	m.queue[index] = run
+
+	m.schedule()
+	return nil
+}
+
+// Queue 返回排队中的运行和当前的节点占用情况
+func (m *RunManager) Queue() ([]*Run, map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queue := append([]*Run(nil), m.queue...)
+	reserved := make(map[string]string, len(m.reserved))
+	for host, id := range m.reserved {
+		reserved[host] = id
+	}
+	return queue, reserved
+}
+
+// hostsOverlap 判断两组节点是否有交集
+func hostsOverlap(a, b []string) bool {
+	set := make(map[string]bool, len(a))
+	for _, host := range a {
+		set[host] = true
+	}
+	for _, host := range b {
+		if set[host] {
+			return true
+		}
+	}
+	return false
+}
+
+// respondSubmitError 返回提交运行失败的响应：节点冲突返回 409，参数错误返回带字段信息的 400，其他请求错误返回 400
+func respondSubmitError(c *gin.Context, err error) {
+	var conflict *HostConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":          err.Error(),
+			"conflict_hosts": conflict.Hosts,
+			"conflict_runs":  conflict.RunIDs,
+		})
+		return
+	}
+
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Invalid parameters",
+			"fields": invalid.Fields,
+		})
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": err.Error(),
+	})
+}
+
+// GetQueue 获取排队中的运行和节点占用情况
+func GetQueue(c *gin.Context) {
+	queue, reserved := defaultRunManager.Queue()
+
+	infos := make([]RunInfo, 0, len(queue))
+	for _, run := range queue {
+		infos = append(infos, run.Info())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":    len(infos),
+		"queue":    infos,
+		"reserved": reserved,
+	})
+}
+
+// MoveQueuedRun 调整排队任务的位置
+func MoveQueuedRun(c *gin.Context) {
+	run, ok := defaultRunManager.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Run not found",
+		})
+		return
+	}
+
+	var req MoveQueueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := defaultRunManager.Move(run, req.Position); err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, run.Info())
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queueState 运行的期望状态，blockedBy 为阻塞它的运行在提交顺序中的下标
+type queueState struct {
+	status    string
+	position  int
+	blockedBy []int
+}
+
+func TestSchedule(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		hosts  [][]string // 按顺序提交的运行所使用的节点
+		action func(t *testing.T, m *RunManager, runs []*Run)
+		want   []queueState
+	}{
+		{
+			desc:  "节点重叠时排队",
+			hosts: [][]string{{"node01", "node02"}, {"node02", "node03"}},
+			want: []queueState{
+				{status: RunStatusRunning},
+				{status: RunStatusQueued, position: 1, blockedBy: []int{0}},
+			},
+		},
+		{
+			desc:  "不能占用排在前面的运行等待的节点",
+			hosts: [][]string{{"node01"}, {"node01", "node02"}, {"node02"}, {"node03"}},
+			want: []queueState{
+				{status: RunStatusRunning},
+				{status: RunStatusQueued, position: 1, blockedBy: []int{0}},
+				{status: RunStatusQueued, position: 2, blockedBy: []int{1}},
+				{status: RunStatusRunning},
+			},
+		},
+		{
+			desc:  "释放的节点不会被后面的运行抢占",
+			hosts: [][]string{{"node01"}, {"node02"}, {"node01", "node02"}, {"node02"}},
+			action: func(t *testing.T, m *RunManager, runs []*Run) {
+				finishStub(t, runs[1], 0)
+				waitHistory(t, runs[1])
+			},
+			want: []queueState{
+				{status: RunStatusRunning},
+				{status: RunStatusSuccess},
+				{status: RunStatusQueued, position: 1, blockedBy: []int{0}},
+				{status: RunStatusQueued, position: 2, blockedBy: []int{2}},
+			},
+		},
+		{
+			desc:  "调整排队位置",
+			hosts: [][]string{{"node01"}, {"node01", "node02"}, {"node01"}},
+			action: func(t *testing.T, m *RunManager, runs []*Run) {
+				if err := m.Move(runs[2], 1); err != nil {
+					t.Fatalf("Move: %v", err)
+				}
+				if err := m.Move(runs[0], 1); err == nil {
+					t.Error("moving a running run should fail")
+				}
+			},
+			want: []queueState{
+				{status: RunStatusRunning},
+				{status: RunStatusQueued, position: 2, blockedBy: []int{0, 2}},
+				{status: RunStatusQueued, position: 1, blockedBy: []int{0}},
+			},
+		},
+		{
+			desc:  "调整位置后节点空闲时立即启动",
+			hosts: [][]string{{"node01"}, {"node01", "node02"}, {"node02"}},
+			action: func(t *testing.T, m *RunManager, runs []*Run) {
+				if err := m.Move(runs[2], 1); err != nil {
+					t.Fatalf("Move: %v", err)
+				}
+			},
+			want: []queueState{
+				{status: RunStatusRunning},
+				{status: RunStatusQueued, position: 1, blockedBy: []int{0, 2}},
+				{status: RunStatusRunning},
+			},
+		},
+		{
+			desc:  "取消排队中的运行后启动下一个",
+			hosts: [][]string{{"node01"}, {"node01", "node02"}, {"node02"}},
+			action: func(t *testing.T, m *RunManager, runs []*Run) {
+				if err := m.Stop(runs[1]); err != nil {
+					t.Fatalf("Stop: %v", err)
+				}
+				if info := runs[1].Info(); !runs[1].Finished() || info.Error != "Cancelled while queued" {
+					t.Errorf("queued run should be cancelled: %+v", info)
+				}
+			},
+			want: []queueState{
+				{status: RunStatusRunning},
+				{status: RunStatusStopped},
+				{status: RunStatusRunning},
+			},
+		},
+		{
+			desc:  "运行结束后启动排队的运行",
+			hosts: [][]string{{"node01"}, {"node01", "node02"}, {"node02"}},
+			action: func(t *testing.T, m *RunManager, runs []*Run) {
+				finishStub(t, runs[0], 0)
+				waitStarted(t, runs[1])
+			},
+			want: []queueState{
+				{status: RunStatusSuccess},
+				{status: RunStatusRunning},
+				{status: RunStatusQueued, position: 1, blockedBy: []int{1}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			m := newTestRunManager(t)
+			runs := make([]*Run, len(tc.hosts))
+			for i, hosts := range tc.hosts {
+				runs[i] = submitStub(t, m, "alice", hosts...)
+			}
+			if tc.action != nil {
+				tc.action(t, m, runs)
+			}
+
+			for i, want := range tc.want {
+				info := runs[i].Info()
+				var blockedBy []int
+				for _, id := range info.BlockedBy {
+					for j, run := range runs {
+						if run.ID() == id {
+							blockedBy = append(blockedBy, j)
+						}
+					}
+				}
+				if info.Status != want.status || info.QueuePosition != want.position || !reflect.DeepEqual(blockedBy, want.blockedBy) {
+					t.Errorf("run %d: status=%s position=%d blocked_by=%v, want %s %d %v",
+						i, info.Status, info.QueuePosition, blockedBy, want.status, want.position, want.blockedBy)
+				}
+			}
+
+			// 只有运行中的任务占用节点
+			queue, reserved := m.Queue()
+			for _, run := range runs {
+				info := run.Info()
+				for _, host := range info.Hosts {
+					if info.Status == RunStatusRunning && reserved[host] != info.ID {
+						t.Errorf("host %s should be reserved by %s, got %q", host, info.ID, reserved[host])
+					}
+				}
+			}
+			for i, run := range queue {
+				if run.Info().QueuePosition != i+1 {
+					t.Errorf("queue[%d] has position %d", i, run.Info().QueuePosition)
+				}
+			}
+		})
+	}
+}
+
+func TestStopQueuedRunHistory(t *testing.T) {
+	m := newTestRunManager(t)
+	running := submitStub(t, m, "alice", "node01")
+	queued := submitStub(t, m, "bob", "node01")
+
+	if err := m.Stop(queued); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	finishStub(t, running, 0)
+	m.Wait()
+
+	meta, err := loadHistoryMeta(queued.ID())
+	if err != nil || meta == nil || meta.Status != RunStatusStopped {
+		t.Errorf("cancelled run should be saved to history: %+v, %v", meta, err)
+	}
+	if _, err := os.Stat(runWorkDir(queued.ID())); !os.IsNotExist(err) {
+		t.Errorf("work directory of the cancelled run should be removed: %v", err)
+	}
+}
+
+func TestRespondSubmitError(t *testing.T) {
+	m := newTestRunManager(t)
+	running := submitStub(t, m, "alice", "node01", "node02")
+
+	params := validParams()
+	params.Launcher = "stub"
+	invalid := params
+	invalid.MapBy = "ppr:8:node; reboot"
+
+	testCases := []struct {
+		desc   string
+		req    RunRequest
+		status int
+		check  func(t *testing.T, body map[string]interface{})
+	}{
+		{
+			desc:   "节点冲突时拒绝",
+			req:    RunRequest{NCCLTestParams: params, OnConflict: ConflictReject, Hosts: []string{"node02", "node03"}},
+			status: http.StatusConflict,
+			check: func(t *testing.T, body map[string]interface{}) {
+				if !reflect.DeepEqual(body["conflict_hosts"], []interface{}{"node02"}) ||
+					!reflect.DeepEqual(body["conflict_runs"], []interface{}{running.ID()}) {
+					t.Errorf("unexpected conflict: %v", body)
+				}
+			},
+		},
+		{
+			desc:   "参数错误返回字段信息",
+			req:    RunRequest{NCCLTestParams: invalid, Hosts: []string{"node03"}},
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, body map[string]interface{}) {
+				fields, _ := body["fields"].(map[string]interface{})
+				if _, ok := fields["map_by"]; !ok {
+					t.Errorf("fields should contain map_by: %v", body)
+				}
+			},
+		},
+		{
+			desc:   "非法的冲突处理方式",
+			req:    RunRequest{NCCLTestParams: params, OnConflict: "wait", Hosts: []string{"node03"}},
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, body map[string]interface{}) {
+				if body["error"] != "invalid on_conflict: wait" || body["fields"] != nil {
+					t.Errorf("unexpected body: %v", body)
+				}
+			},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			run, err := m.Submit(tc.req)
+			if err == nil {
+				t.Fatalf("Submit should fail, got run %s", run.ID())
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			respondSubmitError(c, err)
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d", w.Code, tc.status)
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			tc.check(t, body)
+		})
+	}
+
+	// 被拒绝的运行不留下工作目录
+	entries, _ := os.ReadDir(filepath.Join(DataDir, RunsDir))
+	if len(entries) != 1 {
+		t.Errorf("runs directory should only contain the running run, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// 拓扑问题类型
+const (
+	TopologyMissingRank     = "missing_rank"     // 期望的 rank 没有出现在设备列表中
+	TopologyDuplicateRank   = "duplicate_rank"   // 同一个 rank 出现多次
+	TopologyMissingHost     = "missing_host"     // IP 列表中的节点没有运行任何 rank
+	TopologyUnexpectedHost  = "unexpected_host"  // rank 运行在 IP 列表之外的节点上
+	TopologyGPUCount        = "gpu_count"        // 节点上的 GPU 数与 map_by 不一致
+	TopologyMixedModels     = "mixed_models"     // 使用了不同型号的 GPU
+	TopologyDuplicateDevice = "duplicate_device" // 同一块 GPU 被多个 rank 使用
+)
+
+// TopologyEvent 设备列表解析完成后发送的拓扑检查 SSE 事件，数据为 TopologyReport
+const TopologyEvent = "topology"
+
+// TopologyReport 设备列表与 IP 列表、map_by 的一致性检查结果
+type TopologyReport struct {
+	OK            bool            `json:"ok"`
+	Ranks         int             `json:"ranks"`          // 设备列表中的 rank 数
+	ExpectedRanks int             `json:"expected_ranks"` // 根据 IP 列表和 map_by 推算的 rank 数，无法推算时为 0
+	GPUsPerHost   int             `json:"gpus_per_host"`  // 每个节点期望的 GPU 数，无法推算时为 0
+	Hosts         []HostTopology  `json:"hosts"`
+	Issues        []TopologyIssue `json:"issues"`
+}
+
+// HostTopology 一个节点上的 rank
+type HostTopology struct {
+	Host   string   `json:"host"`
+	Ranks  []int    `json:"ranks"`
+	Models []string `json:"models"`
+}
+
+// TopologyIssue 拓扑检查发现的问题
+type TopologyIssue struct {
+	Type    string `json:"type"`
+	Host    string `json:"host,omitempty"`
+	Ranks   []int  `json:"ranks,omitempty"`
+	Message string `json:"message"`
+}
+
+// CheckTopology 根据 IP 列表和 map_by（如 ppr:8:node）检查设备列表，没有设备列表时返回 nil
+// 设备列表中的节点名来自 nccl-tests 所在节点的 hostname，IP 列表中是 IP 地址时无法逐个对应，
+// 此时只比较节点数量
+func CheckTopology(devices []DeviceInfo, hosts []string, params NCCLTestParams) *TopologyReport {
+	if len(devices) == 0 {
+		return nil
+	}
+
+	sorted := append([]DeviceInfo(nil), devices...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Rank < sorted[j].Rank
+	})
+
+	report := &TopologyReport{
+		Ranks:  len(sorted),
+		Hosts:  []HostTopology{},
+		Issues: []TopologyIssue{},
+	}
+
+	// 每个 GPU 在设备列表中占一行，-g 大于 1 时一个进程使用多个 GPU
+	gpusPerProc := params.GPUsPerThread
+	if gpusPerProc < 1 {
+		gpusPerProc = 1
+	}
+	names := hostNames(hosts)
+	if ppr := procsPerNode(params.MapBy); ppr > 0 {
+		report.GPUsPerHost = ppr * gpusPerProc
+		report.ExpectedRanks = report.GPUsPerHost * len(names)
+	}
+
+	// 按节点分组，同时检查重复的 rank 和 GPU
+	byHost := make(map[string]*HostTopology)
+	var order []string
+	rankCount := make(map[int]int)
+	deviceRanks := make(map[string][]int)
+	var deviceOrder []string
+	models := make(map[string][]string) // 型号 -> 节点
+	var modelOrder []string
+
+	for _, d := range sorted {
+		h, ok := byHost[d.Host]
+		if !ok {
+			h = &HostTopology{Host: d.Host, Ranks: []int{}, Models: []string{}}
+			byHost[d.Host] = h
+			order = append(order, d.Host)
+		}
+		h.Ranks = append(h.Ranks, d.Rank)
+		if !slices.Contains(h.Models, d.Model) {
+			h.Models = append(h.Models, d.Model)
+			if _, ok := models[d.Model]; !ok {
+				modelOrder = append(modelOrder, d.Model)
+			}
+			models[d.Model] = append(models[d.Model], d.Host)
+		}
+
+		rankCount[d.Rank]++
+
+		device := d.BusID
+		if device == "" {
+			device = fmt.Sprintf("device %d", d.Device)
+		}
+		key := d.Host + " " + device
+		if _, ok := deviceRanks[key]; !ok {
+			deviceOrder = append(deviceOrder, key)
+		}
+		deviceRanks[key] = append(deviceRanks[key], d.Rank)
+	}
+	for _, host := range order {
+		report.Hosts = append(report.Hosts, *byHost[host])
+	}
+
+	addIssue := func(issue TopologyIssue) {
+		report.Issues = append(report.Issues, issue)
+	}
+
+	// 缺失和重复的 rank
+	expected := report.ExpectedRanks
+	if expected == 0 {
+		expected = sorted[len(sorted)-1].Rank + 1
+	}
+	var missing, duplicated []int
+	for rank := 0; rank < expected; rank++ {
+		if rankCount[rank] == 0 {
+			missing = append(missing, rank)
+		}
+	}
+	for _, d := range sorted {
+		if rankCount[d.Rank] > 1 && !slices.Contains(duplicated, d.Rank) {
+			duplicated = append(duplicated, d.Rank)
+		}
+	}
+	if len(missing) > 0 {
+		addIssue(TopologyIssue{
+			Type:    TopologyMissingRank,
+			Ranks:   missing,
+			Message: fmt.Sprintf("%d of %d ranks are missing from the device list: %s", len(missing), expected, formatRanks(missing)),
+		})
+	}
+	if len(duplicated) > 0 {
+		addIssue(TopologyIssue{
+			Type:    TopologyDuplicateRank,
+			Ranks:   duplicated,
+			Message: fmt.Sprintf("ranks listed more than once: %s", formatRanks(duplicated)),
+		})
+	}
+
+	// 节点与 IP 列表是否一致
+	inList := make(map[string]bool, len(names))
+	comparable := false
+	for _, name := range names {
+		inList[name] = true
+		if byHost[name] != nil {
+			comparable = true
+		}
+	}
+	switch {
+	case comparable:
+		for _, name := range names {
+			if byHost[name] == nil {
+				addIssue(TopologyIssue{Type: TopologyMissingHost, Host: name, Message: fmt.Sprintf("%s has no ranks", name)})
+			}
+		}
+		for _, host := range order {
+			if !inList[host] {
+				addIssue(TopologyIssue{Type: TopologyUnexpectedHost, Host: host, Ranks: byHost[host].Ranks,
+					Message: fmt.Sprintf("%s is not in the iplist but runs ranks %s", host, formatRanks(byHost[host].Ranks))})
+			}
+		}
+	case len(names) > 0 && len(order) != len(names):
+		addIssue(TopologyIssue{
+			Type:    TopologyMissingHost,
+			Message: fmt.Sprintf("ranks ran on %d hosts but the iplist has %d", len(order), len(names)),
+		})
+	}
+
+	// 每个节点的 GPU 数
+	if report.GPUsPerHost > 0 {
+		for _, host := range order {
+			if n := len(byHost[host].Ranks); n != report.GPUsPerHost {
+				addIssue(TopologyIssue{Type: TopologyGPUCount, Host: host, Ranks: byHost[host].Ranks,
+					Message: fmt.Sprintf("%s has %d GPUs, expected %d from map_by %s", host, n, report.GPUsPerHost, params.MapBy)})
+			}
+		}
+	}
+
+	// GPU 型号
+	if len(modelOrder) > 1 {
+		parts := make([]string, 0, len(modelOrder))
+		for _, model := range modelOrder {
+			parts = append(parts, fmt.Sprintf("%s on %s", model, strings.Join(models[model], ", ")))
+		}
+		addIssue(TopologyIssue{Type: TopologyMixedModels, Message: "mixed GPU models: " + strings.Join(parts, "; ")})
+	}
+
+	// 同一块 GPU 被多个 rank 使用
+	for _, key := range deviceOrder {
+		if ranks := deviceRanks[key]; len(ranks) > 1 {
+			host := strings.SplitN(key, " ", 2)[0]
+			addIssue(TopologyIssue{Type: TopologyDuplicateDevice, Host: host, Ranks: ranks,
+				Message: fmt.Sprintf("ranks %s share %s", formatRanks(ranks), key)})
+		}
+	}
+
+	report.OK = len(report.Issues) == 0
+	return report
+}
+
+// Messages 返回所有问题的描述
+func (r *TopologyReport) Messages() []string {
+	if r == nil {
+		return nil
+	}
+	messages := make([]string, 0, len(r.Issues))
+	for _, issue := range r.Issues {
+		messages = append(messages, issue.Message)
+	}
+	return messages
+}
+
+// formatRanks 将 rank 列表格式化为区间，如 0-3,8,10-11
+func formatRanks(ranks []int) string {
+	var parts []string
+	for i := 0; i < len(ranks); {
+		j := i
+		for j+1 < len(ranks) && ranks[j+1] == ranks[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", ranks[i], ranks[j]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d", ranks[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
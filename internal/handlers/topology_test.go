@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+// topologyDevices 生成每个节点 perHost 个 GPU 的设备列表
+func topologyDevices(hosts []string, perHost int) []DeviceInfo {
+	var devices []DeviceInfo
+	for i, host := range hosts {
+		for d := 0; d < perHost; d++ {
+			devices = append(devices, DeviceInfo{
+				Rank: i*perHost + d, Host: host, Device: d,
+				BusID: fakeBusIDs[d], Model: "NVIDIA H200",
+			})
+		}
+	}
+	return devices
+}
+
+func TestCheckTopology(t *testing.T) {
+	params := validParams()
+	params.MapBy = "ppr:4:node"
+	hosts := []string{"node01 slots=4", "node02 slots=4"}
+
+	testCases := []struct {
+		desc    string
+		devices func() []DeviceInfo
+		hosts   []string
+		issues  []string // 期望的问题类型，按出现顺序
+	}{
+		{
+			desc:    "一致",
+			devices: func() []DeviceInfo { return topologyDevices([]string{"node01", "node02"}, 4) },
+			hosts:   hosts,
+		},
+		{
+			desc: "缺失 rank",
+			devices: func() []DeviceInfo {
+				d := topologyDevices([]string{"node01", "node02"}, 4)
+				return append(d[:5], d[6:]...)
+			},
+			hosts:  hosts,
+			issues: []string{TopologyMissingRank, TopologyGPUCount},
+		},
+		{
+			desc:    "节点不在 IP 列表中",
+			devices: func() []DeviceInfo { return topologyDevices([]string{"node01", "node03"}, 4) },
+			hosts:   hosts,
+			issues:  []string{TopologyMissingHost, TopologyUnexpectedHost},
+		},
+		{
+			desc:    "IP 列表是 IP 地址时只比较节点数量",
+			devices: func() []DeviceInfo { return topologyDevices([]string{"node01"}, 8) },
+			hosts:   []string{"10.0.0.1", "10.0.0.2"},
+			issues:  []string{TopologyMissingHost, TopologyGPUCount},
+		},
+		{
+			desc: "GPU 型号不同",
+			devices: func() []DeviceInfo {
+				d := topologyDevices([]string{"node01", "node02"}, 4)
+				for i := 4; i < 8; i++ {
+					d[i].Model = "NVIDIA H100 80GB HBM3"
+				}
+				return d
+			},
+			hosts:  hosts,
+			issues: []string{TopologyMixedModels},
+		},
+		{
+			desc: "同一块 GPU 被多个 rank 使用",
+			devices: func() []DeviceInfo {
+				d := topologyDevices([]string{"node01", "node02"}, 4)
+				d[1].BusID, d[1].Device = d[0].BusID, d[0].Device
+				return d
+			},
+			hosts:  hosts,
+			issues: []string{TopologyDuplicateDevice},
+		},
+		{
+			desc: "重复的 rank",
+			devices: func() []DeviceInfo {
+				d := topologyDevices([]string{"node01", "node02"}, 4)
+				d[7].Rank = 6
+				return d
+			},
+			hosts:  hosts,
+			issues: []string{TopologyMissingRank, TopologyDuplicateRank},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			report := CheckTopology(tc.devices(), tc.hosts, params)
+			if report == nil {
+				t.Fatal("report should not be nil")
+			}
+
+			var issues []string
+			for _, issue := range report.Issues {
+				issues = append(issues, issue.Type)
+			}
+			if !reflect.DeepEqual(issues, tc.issues) || report.OK != (len(tc.issues) == 0) {
+				t.Errorf("issues = %v (ok=%v), want %v: %v", issues, report.OK, tc.issues, report.Messages())
+			}
+		})
+	}
+
+	if CheckTopology(nil, hosts, params) != nil {
+		t.Errorf("report should be nil without a device list")
+	}
+}
+
+func TestFormatRanks(t *testing.T) {
+	if got := formatRanks([]int{0, 1, 2, 3, 8, 10, 11}); got != "0-3,8,10-11" {
+		t.Errorf("formatRanks = %q", got)
+	}
+}
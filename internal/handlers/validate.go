@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 参数校验使用的正则表达式
+var (
+	// 网卡名称（最长 15 个字符）或 CIDR 网段，多个用逗号分隔，可用 ^ 前缀表示排除
+	interfacePattern = regexp.MustCompile(`^\^?([A-Za-z0-9][A-Za-z0-9_.-]{0,14}|\d{1,3}(\.\d{1,3}){3}/\d{1,2})(,([A-Za-z0-9][A-Za-z0-9_.-]{0,14}|\d{1,3}(\.\d{1,3}){3}/\d{1,2}))*$`)
+	// Open MPI --map-by 语法：[ppr:N:]<object>[:modifier[=N]]...
+	mapByPattern = regexp.MustCompile(`^(ppr:[1-9]\d*:)?(slot|hwthread|core|l1cache|l2cache|l3cache|package|socket|numa|node|board)(:([A-Za-z]+|PE=[1-9]\d*))*$`)
+	// 大小参数：数字加可选的 K/M/G 后缀
+	sizePattern = regexp.MustCompile(`^\d+[KMGkmg]?$`)
+	// IP 列表文件名
+	filenamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+// ncclDebugLevels NCCL_DEBUG 允许的取值
+var ncclDebugLevels = map[string]bool{
+	"VERSION": true,
+	"WARN":    true,
+	"INFO":    true,
+	"ABORT":   true,
+	"TRACE":   true,
+}
+
+// ncclCollectives 支持的集合通信类型
+var ncclCollectives = map[string]bool{
+	"all_reduce":     true,
+	"all_gather":     true,
+	"reduce_scatter": true,
+	"alltoall":       true,
+	"broadcast":      true,
+	"reduce":         true,
+	"sendrecv":       true,
+}
+
+// ncclDatatypes nccl-tests -d 允许的数据类型
+var ncclDatatypes = map[string]bool{
+	"int8": true, "char": true, "uint8": true,
+	"int32": true, "int": true, "uint32": true,
+	"int64": true, "uint64": true,
+	"half": true, "float16": true, "float": true, "float32": true,
+	"double": true, "float64": true, "bfloat16": true,
+	"f8e4m3": true, "f8e5m2": true,
+	"all": true,
+}
+
+// ncclOps nccl-tests -o 允许的规约操作
+var ncclOps = map[string]bool{
+	"sum": true, "prod": true, "max": true, "min": true, "avg": true, "mulsum": true, "all": true,
+}
+
+// ValidationError 参数校验错误，Fields 为字段名到错误信息的映射
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, name+": "+e.Fields[name])
+	}
+	return "invalid parameters: " + strings.Join(msgs, "; ")
+}
+
+// Validate 校验测试参数，所有会出现在命令行中的字段都必须通过严格校验
+func (p NCCLTestParams) Validate() error {
+	fields := make(map[string]string)
+
+	if !mapByPattern.MatchString(p.MapBy) {
+		fields["map_by"] = "must be an Open MPI map-by spec such as ppr:8:node"
+	}
+	if !interfacePattern.MatchString(p.OOBTCPInterface) {
+		fields["oob_tcp_interface"] = "must be an interface name or CIDR list such as bond0"
+	}
+	if !interfacePattern.MatchString(p.BTLTCPInterface) {
+		fields["btl_tcp_interface"] = "must be an interface name or CIDR list such as bond0"
+	}
+
+	if p.NCCLIBGIDIndex < 0 || p.NCCLIBGIDIndex > 255 {
+		fields["nccl_ib_gid_index"] = "must be between 0 and 255"
+	}
+	if p.NCCLMinChannels < 1 || p.NCCLMinChannels > 256 {
+		fields["nccl_min_channels"] = "must be between 1 and 256"
+	}
+	if p.NCCLIBQPSPerConnection < 1 || p.NCCLIBQPSPerConnection > 128 {
+		fields["nccl_ib_qps_per_connection"] = "must be between 1 and 128"
+	}
+
+	begin, beginErr := validateSize(p.TestSizeBegin)
+	if beginErr != "" {
+		fields["test_size_begin"] = beginErr
+	}
+	end, endErr := validateSize(p.TestSizeEnd)
+	if endErr != "" {
+		fields["test_size_end"] = endErr
+	}
+	if beginErr == "" && endErr == "" && begin >= 0 && end >= 0 && begin > end {
+		fields["test_size_end"] = "must not be smaller than test_size_begin"
+	}
+
+	if p.Iters < 0 {
+		fields["iters"] = "must not be negative"
+	}
+	if p.Timeout < 0 {
+		fields["timeout"] = "must not be negative"
+	}
+
+	if p.Collective != "" && !ncclCollectives[p.Collective] {
+		fields["collective"] = "must be one of all_reduce, all_gather, reduce_scatter, alltoall, broadcast, reduce, sendrecv"
+	}
+	if p.Datatype != "" && !ncclDatatypes[p.Datatype] {
+		fields["datatype"] = "must be an nccl-tests datatype such as float, half, bfloat16, int32 or all"
+	}
+	if p.Op != "" && !ncclOps[p.Op] {
+		fields["op"] = "must be one of sum, prod, max, min, avg, mulsum, all"
+	}
+	// 倍增系数为 1 时测试大小不再增长，无法到达 test_size_end
+	if p.StepFactor < 0 || p.StepFactor == 1 {
+		fields["step_factor"] = "must be 0 (unset) or at least 2"
+	}
+	if _, msg := validateSize(p.StepBytes); msg != "" {
+		fields["step_bytes"] = msg
+	} else if p.StepFactor > 0 && sizeArg(p.StepBytes) != "" {
+		fields["step_bytes"] = "cannot be used together with step_factor"
+	}
+	if p.WarmupIters != nil && *p.WarmupIters < 0 {
+		fields["warmup_iters"] = "must not be negative"
+	}
+	if p.Check != nil && *p.Check < 0 {
+		fields["check"] = "must not be negative"
+	}
+	if p.GPUsPerThread < 0 || p.GPUsPerThread > 16 {
+		fields["gpus_per_thread"] = "must be between 0 and 16"
+	}
+	if p.Blocking != nil && *p.Blocking != 0 && *p.Blocking != 1 {
+		fields["blocking"] = "must be 0 or 1"
+	}
+	if p.CUDAGraph != nil && *p.CUDAGraph < 0 {
+		fields["cuda_graph"] = "must not be negative"
+	}
+
+	if p.NCCLDebugLevel != "" && !ncclDebugLevels[p.NCCLDebugLevel] {
+		fields["nccl_debug_level"] = "must be one of VERSION, WARN, INFO, ABORT, TRACE"
+	}
+
+	if !filenamePattern.MatchString(p.IPListFile) {
+		fields["iplist_file"] = "must contain only letters, digits, '.', '_' and '-'"
+	}
+
+	for field, msg := range checkEnv(p.Env) {
+		fields[field] = msg
+	}
+
+	if _, err := getLauncher(p.Launcher); err != nil {
+		fields["launcher"] = fmt.Sprintf("must be one of %s", strings.Join(launcherNames(), ", "))
+	}
+
+	if p.Criteria != "" {
+		if _, err := loadCriteria(p.Criteria); err != nil {
+			fields["criteria"] = err.Error()
+		}
+	}
+	for field, msg := range checkThresholds(p.Thresholds) {
+		fields[field] = msg
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// validateSize 校验大小参数（整数或 8K/128M/1G 形式的字符串），返回字节数；未设置时返回 -1
+func validateSize(v interface{}) (int64, string) {
+	switch s := v.(type) {
+	case nil:
+		return -1, ""
+	case float64:
+		if s < 0 || s != math.Trunc(s) || s > math.MaxInt64 {
+			return 0, "must be a non-negative integer"
+		}
+		return int64(s), ""
+	case string:
+		if s == "" {
+			return -1, ""
+		}
+		if !sizePattern.MatchString(s) {
+			return 0, "must be a number with an optional K/M/G suffix such as 8K, 128M, 1G"
+		}
+		n, err := parseSize(s)
+		if err != nil {
+			return 0, err.Error()
+		}
+		return n, ""
+	default:
+		return 0, "must be an integer or a size string"
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func validParams() NCCLTestParams {
+	return NCCLTestParams{
+		MapBy:                  "ppr:8:node",
+		OOBTCPInterface:        "bond0",
+		BTLTCPInterface:        "bond0",
+		NCCLIBGIDIndex:         3,
+		NCCLMinChannels:        32,
+		NCCLIBQPSPerConnection: 8,
+		TestSizeBegin:          "8K",
+		TestSizeEnd:            float64(1 << 30),
+		Iters:                  20,
+		NCCLDebugLevel:         "WARN",
+		IPListFile:             "default",
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		modify func(p *NCCLTestParams)
+		field  string // 期望报错的字段，空表示校验通过
+	}{
+		{"合法参数", func(p *NCCLTestParams) {}, ""},
+		{"map_by 带修饰符", func(p *NCCLTestParams) { p.MapBy = "ppr:4:socket:PE=2" }, ""},
+		{"网卡 CIDR 列表", func(p *NCCLTestParams) { p.OOBTCPInterface = "10.0.0.0/8,eth0" }, ""},
+		{"map_by 注入", func(p *NCCLTestParams) { p.MapBy = "ppr:8:node; rm -rf /" }, "map_by"},
+		{"网卡名注入", func(p *NCCLTestParams) { p.OOBTCPInterface = "bond0 $(id)" }, "oob_tcp_interface"},
+		{"网卡名过长", func(p *NCCLTestParams) { p.BTLTCPInterface = "abcdefghijklmnop" }, "btl_tcp_interface"},
+		{"DEBUG 级别非法", func(p *NCCLTestParams) { p.NCCLDebugLevel = "INFO`id`" }, "nccl_debug_level"},
+		{"大小参数注入", func(p *NCCLTestParams) { p.TestSizeBegin = "1K;reboot" }, "test_size_begin"},
+		{"大小参数为小数", func(p *NCCLTestParams) { p.TestSizeEnd = 1.5 }, "test_size_end"},
+		{"大小范围颠倒", func(p *NCCLTestParams) { p.TestSizeBegin = "2G" }, "test_size_end"},
+		{"文件名路径遍历", func(p *NCCLTestParams) { p.IPListFile = "../etc/passwd" }, "iplist_file"},
+		{"未知启动器", func(p *NCCLTestParams) { p.Launcher = "pdsh" }, "launcher"},
+		{"多次校验", func(p *NCCLTestParams) { n := 5; p.Check = &n }, ""},
+		{"倍增系数", func(p *NCCLTestParams) { p.StepFactor = 2 }, ""},
+		{"倍增系数为 1", func(p *NCCLTestParams) { p.StepFactor = 1 }, "step_factor"},
+		{"倍增系数为负", func(p *NCCLTestParams) { p.StepFactor = -2 }, "step_factor"},
+		{"校验次数为负", func(p *NCCLTestParams) { n := -1; p.Check = &n }, "check"},
+		{"白名单内的环境变量", func(p *NCCLTestParams) {
+			p.Env = map[string]string{"NCCL_ALGO": "Ring,Tree", "NCCL_IB_HCA": "=mlx5_0:1,mlx5_1:1", "NCCL_CROSS_NIC": "1"}
+		}, ""},
+		{"白名单外的环境变量", func(p *NCCLTestParams) { p.Env = map[string]string{"LD_PRELOAD": "/tmp/x.so"} }, "env.LD_PRELOAD"},
+		{"环境变量值不匹配", func(p *NCCLTestParams) { p.Env = map[string]string{"NCCL_CROSS_NIC": "3"} }, "env.NCCL_CROSS_NIC"},
+		{"覆盖专用字段的环境变量", func(p *NCCLTestParams) { p.Env = map[string]string{"NCCL_DEBUG": "INFO"} }, "env.NCCL_DEBUG"},
+		{"加载插件的环境变量", func(p *NCCLTestParams) { p.Env = map[string]string{"NCCL_NET_PLUGIN": "/tmp/x.so"} }, "env.NCCL_NET_PLUGIN"},
+		{"写文件的环境变量", func(p *NCCLTestParams) { p.Env = map[string]string{"NCCL_DEBUG_FILE": "/etc/cron.d/x"} }, "env.NCCL_DEBUG_FILE"},
+		{"白名单外的 NCCL 变量", func(p *NCCLTestParams) { p.Env = map[string]string{"NCCL_TOPO_DUMP": "1"} }, "env.NCCL_TOPO_DUMP"},
+	}
+
+	for _, tc := range testCases {
+		p := validParams()
+		tc.modify(&p)
+
+		err := p.Validate()
+		if tc.field == "" {
+			if err != nil {
+				t.Errorf("[%s] 预期通过，实际: %v", tc.desc, err)
+			}
+			continue
+		}
+
+		var invalid *ValidationError
+		if !errors.As(err, &invalid) {
+			t.Errorf("[%s] 预期字段 %s 报错，实际: %v", tc.desc, tc.field, err)
+			continue
+		}
+		if _, ok := invalid.Fields[tc.field]; !ok {
+			t.Errorf("[%s] 预期字段 %s 报错，实际: %v", tc.desc, tc.field, invalid.Fields)
+		}
+	}
+}
+
+func TestRenderCommandRoundTrip(t *testing.T) {
+	groups := [][]string{
+		{"printf", "%s|"},
+		{"plain", "with space", "it's", "$(id)", "^ucx"},
+	}
+
+	// 渲染后的命令在 shell 中执行时必须得到完全相同的 argv
+	out, err := exec.Command("sh", "-c", renderCommand(groups)).Output()
+	if err != nil {
+		t.Fatalf("执行渲染后的命令失败: %v", err)
+	}
+
+	want := strings.Join(groups[1], "|") + "|"
+	if string(out) != want {
+		t.Errorf("预期: %q, 实际: %q", want, string(out))
+	}
+}
+
+func TestCheckEnvRulesDenied(t *testing.T) {
+	rules := []EnvRule{{Name: "*", Value: `^.*$`}}
+	env := map[string]string{
+		"NCCL_TUNER_PLUGIN":    "libx.so",
+		"NCCL_TOPO_FILE":       "/etc/shadow",
+		"LD_LIBRARY_PATH":      "/tmp",
+		"LD_PRELOAD":           "/tmp/x.so",
+		"NCCL_IB_TIMEOUT":      "22",
+		"UCX_RNDV_THRESH":      "8K",
+		"NCCL_PROFILER_PLUGIN": "x",
+	}
+
+	fields := checkEnvRules(env, rules)
+	for _, name := range []string{"NCCL_TUNER_PLUGIN", "NCCL_TOPO_FILE", "LD_LIBRARY_PATH", "LD_PRELOAD", "NCCL_PROFILER_PLUGIN"} {
+		if fields["env."+name] == "" {
+			t.Errorf("%s should be denied even with a catch-all rule", name)
+		}
+	}
+	if len(fields) != 5 {
+		t.Errorf("unexpected errors: %v", fields)
+	}
+}
+
+func TestDefaultParamsUseWrapper(t *testing.T) {
+	// 默认参数不能改变前端原有的行为：使用 nccl_test 启动脚本，不额外传递 nccl-tests 参数
+	if bin := ncclTestBinary(DefaultParams); bin != NCCLTestPath {
+		t.Errorf("default binary = %s, want %s", bin, NCCLTestPath)
+	}
+	args := ncclTestArgs(DefaultParams)
+	want := []string{"-b", "1", "-e", "1", "-n", "20"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("default args = %v, want %v", args, want)
+	}
+}
+
+func TestSlurmLauncherNodeNames(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		hosts []string
+		ok    bool
+	}{
+		{desc: "节点名", hosts: []string{"gpu-001", "gpu-002 slots=8"}, ok: true},
+		{desc: "IPv4 地址", hosts: []string{"gpu-001", "10.0.0.2 slots=8"}},
+		{desc: "IPv6 地址", hosts: []string{"fe80::1"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			spec, err := (&SlurmLauncher{}).Prepare(validParams(), tc.hosts, t.TempDir())
+			if tc.ok && (err != nil || !strings.Contains(spec.Command, "--nodelist=gpu-001,gpu-002")) {
+				t.Errorf("unexpected result: %v %+v", err, spec)
+			}
+			var invalid *ValidationError
+			if !tc.ok && (!errors.As(err, &invalid) || invalid.Fields["iplist_file"] == "") {
+				t.Errorf("expected iplist_file error, got %v", err)
+			}
+		})
+	}
+}